@@ -0,0 +1,107 @@
+package bot
+
+import (
+	"github.com/nlopes/slack"
+)
+
+// Limits imposed by Slack on Block Kit layouts.
+const (
+	maxSectionText   = 3000
+	maxSectionFields = 10
+)
+
+// Button is an interactive button element.
+type Button struct {
+	ActionID string
+	Text     string
+	Value    string
+	URL      string
+	Style    slack.Style // slack.StylePrimary, slack.StyleDanger or default
+}
+
+// BlockBuilder builds a Block Kit layout. Text is formatted as mrkdwn.
+//
+// https://api.slack.com/block-kit
+type BlockBuilder struct {
+	blocks []slack.Block
+}
+
+// NewBlocks creates an empty BlockBuilder.
+func NewBlocks() *BlockBuilder {
+	return &BlockBuilder{}
+}
+
+// Section adds a section with text, truncated to Slack's limit.
+func (bb *BlockBuilder) Section(text string) *BlockBuilder {
+	bb.blocks = append(bb.blocks, slack.NewSectionBlock(mrkdwn(truncate(text, maxSectionText)), nil, nil))
+	return bb
+}
+
+// Fields adds the fields in two columns. Fields are split across as many
+// sections as necessary.
+func (bb *BlockBuilder) Fields(fields ...string) *BlockBuilder {
+	for len(fields) > 0 {
+		n := len(fields)
+		if n > maxSectionFields {
+			n = maxSectionFields
+		}
+
+		objs := make([]*slack.TextBlockObject, n)
+		for i, f := range fields[:n] {
+			objs[i] = mrkdwn(f)
+		}
+		bb.blocks = append(bb.blocks, slack.NewSectionBlock(nil, objs, nil))
+
+		fields = fields[n:]
+	}
+	return bb
+}
+
+// Context adds a context block with texts, displayed in small print.
+func (bb *BlockBuilder) Context(texts ...string) *BlockBuilder {
+	elements := make([]slack.MixedElement, len(texts))
+	for i, t := range texts {
+		elements[i] = mrkdwn(t)
+	}
+	bb.blocks = append(bb.blocks, slack.NewContextBlock("", elements...))
+	return bb
+}
+
+// Divider adds a divider.
+func (bb *BlockBuilder) Divider() *BlockBuilder {
+	bb.blocks = append(bb.blocks, slack.NewDividerBlock())
+	return bb
+}
+
+// Buttons adds an actions block with buttons. Clicks are delivered to the
+// InteractionHandler as InteractionBlockActions with Button.ActionID.
+func (bb *BlockBuilder) Buttons(buttons ...Button) *BlockBuilder {
+	elements := make([]slack.BlockElement, len(buttons))
+	for i, b := range buttons {
+		e := slack.NewButtonBlockElement(b.ActionID, b.Value, slack.NewTextBlockObject(slack.PlainTextType, b.Text, true, false))
+		e.URL = b.URL
+		e.Style = b.Style
+		elements[i] = e
+	}
+	bb.blocks = append(bb.blocks, slack.NewActionBlock("", elements...))
+	return bb
+}
+
+// Blocks returns the blocks added so far.
+func (bb *BlockBuilder) Blocks() []slack.Block {
+	return bb.blocks
+}
+
+func mrkdwn(text string) *slack.TextBlockObject {
+	return slack.NewTextBlockObject(slack.MarkdownType, text, false, false)
+}
+
+// truncate shortens s to at most n characters, marking truncated text
+// with an ellipsis.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,37 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nlopes/slack"
+)
+
+func TestBlockBuilder(t *testing.T) {
+	t.Run("splits fields across sections", func(t *testing.T) {
+		fields := make([]string, 14)
+		for i := range fields {
+			fields[i] = "field"
+		}
+		blocks := NewBlocks().Fields(fields...).Blocks()
+
+		if len(blocks) != 2 {
+			t.Fatalf("expected 2 blocks, got %d", len(blocks))
+		}
+		for i, expected := range []int{10, 4} {
+			section := blocks[i].(*slack.SectionBlock)
+			if len(section.Fields) != expected {
+				t.Errorf("expected: %d fields\nactual: %d fields", expected, len(section.Fields))
+			}
+		}
+	})
+
+	t.Run("truncates long sections", func(t *testing.T) {
+		blocks := NewBlocks().Section(strings.Repeat("x", 4000)).Blocks()
+
+		text := blocks[0].(*slack.SectionBlock).Text.Text
+		if n := len([]rune(text)); n != maxSectionText || !strings.HasSuffix(text, "…") {
+			t.Errorf("expected %d characters ending in an ellipsis, got %d", maxSectionText, n)
+		}
+	})
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
 
-	"cloud.google.com/go/trace"
+	"github.com/gobridge/gopher/health"
 	"github.com/nlopes/slack"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Logger function
@@ -33,12 +36,23 @@ type Message struct {
 }
 
 // Responder provides methods for responding to messages.
+//
+// The returned Reply can be used to update or delete the response. It is nil
+// if responding failed or the response can't be edited.
 type Responder interface {
-	Respond(ctx context.Context, msg string)
-	RespondUnfurled(ctx context.Context, msg string)
-	RespondWithAttachment(ctx context.Context, msg, attachment string)
-	RespondPrivate(ctx context.Context, msg string)
-	RespondPrivateWithAttachment(ctx context.Context, msg, attachment string)
+	Respond(ctx context.Context, msg string) *Reply
+	RespondUnfurled(ctx context.Context, msg string) *Reply
+	RespondWithAttachment(ctx context.Context, msg, attachment string) *Reply
+	RespondPrivate(ctx context.Context, msg string) *Reply
+	RespondPrivateWithAttachment(ctx context.Context, msg, attachment string) *Reply
+	// RespondWithBlocks responds with a Block Kit layout, msg is used as
+	// the fallback text in notifications.
+	RespondWithBlocks(ctx context.Context, msg string, blocks ...slack.Block) *Reply
+	RespondPrivateWithBlocks(ctx context.Context, msg string, blocks ...slack.Block) *Reply
+	// RespondEphemeral responds with a message only visible to the user
+	// who sent the message. If that isn't possible the response is sent as
+	// a direct message.
+	RespondEphemeral(ctx context.Context, msg string, blocks ...slack.Block) *Reply
 	React(ctx context.Context, reaction string)
 }
 
@@ -60,14 +74,56 @@ type JoinResponder interface {
 	RespondPrivate(ctx context.Context, msg string)
 }
 
+// A ReactionHandler responds to a reaction added to a message.
+type ReactionHandler interface {
+	Handle(context.Context, Reaction)
+}
+
+// ReactionHandlerFunc adapts a function to be a ReactionHandler.
+type ReactionHandlerFunc func(context.Context, Reaction)
+
+// Handle calls rh(ctx, r).
+func (rh ReactionHandlerFunc) Handle(ctx context.Context, r Reaction) {
+	rh(ctx, r)
+}
+
+// Reaction contains the slack.ReactionAddedEvent and the reply it was added
+// to, if any.
+type Reaction struct {
+	Event  *slack.ReactionAddedEvent
+	Reply  *Reply // Bot reply reacted to, nil for other messages.
+	Author string // User who sent the message Reply responds to.
+}
+
 // Bot structure
 type Bot struct {
-	devMode     bool
-	logf        Logger
-	slack       *slack.Client
-	trace       *trace.Client
-	handler     Handler
-	joinHandler JoinHandler
+	devMode   bool
+	logf      Logger
+	transport Transport
+	handlers  atomic.Value // *handlerSet
+	replies   replyLog
+	events    *health.Check
+
+	// Slash commands and interactions received from the EventSource are
+	// handled with these, see SetRequestHandlers.
+	slashResponseType string
+	interactions      InteractionHandler
+
+	// base is the parent of the contexts passed to handlers, it's
+	// cancelled when Shutdown gives up waiting for them.
+	base    context.Context
+	cancel  context.CancelFunc
+	mu      sync.Mutex
+	closing bool
+	pool    *pool
+
+	// received is closed once the events received before the context
+	// passed to Init was done are dispatched, nil before Init.
+	received chan struct{}
+
+	// lastHandled is the number of events handled at the last
+	// CheckWorkers.
+	lastHandled int64
 
 	msgprefix string
 	id        string
@@ -75,25 +131,65 @@ type Bot struct {
 }
 
 // New will create a new Bot.
-func New(sc *slack.Client, tc *trace.Client, devMode bool, log Logger, h Handler, jh JoinHandler) *Bot {
-	return &Bot{
-		devMode:     devMode,
-		logf:        log,
-		slack:       sc,
-		trace:       tc,
-		handler:     h,
-		joinHandler: jh,
+//
+// jh and rh may be nil when the bot doesn't respond to team joins or
+// reactions.
+func New(t Transport, devMode bool, log Logger, h Handler, jh JoinHandler, rh ReactionHandler) *Bot {
+	base, cancel := context.WithCancel(context.Background())
+	b := &Bot{
+		base:      base,
+		cancel:    cancel,
+		pool:      newPool(DefaultWorkers, DefaultQueueSize),
+		devMode:   devMode,
+		logf:      log,
+		transport: t,
+
+		slashResponseType: slashResponseType(false),
 	}
+	b.SetHandlers(h, jh, rh)
+	return b
+}
+
+// SetRequestHandlers sets how slash commands and interactions received from
+// the EventSource, as with Socket Mode, are handled: like SlashCommands with
+// inChannel and Interactions with ih do for HTTP requests. By default, slash
+// command responses are ephemeral and interactions are ignored.
+//
+// It must be called before Init.
+func (b *Bot) SetRequestHandlers(inChannel bool, ih InteractionHandler) {
+	b.slashResponseType = slashResponseType(inChannel)
+	b.interactions = ih
 }
 
-// Init must be called before anything else in order to initialize the bot
+// handlerSet are the handlers of a Bot, replaced together by SetHandlers.
+type handlerSet struct {
+	message  Handler
+	join     JoinHandler
+	reaction ReactionHandler
+}
+
+// SetHandlers replaces the handlers passed to New, for instance when the
+// configuration they're built from changes. Events dispatched from now on use
+// the new handlers, while handlers already running finish with the old ones.
+func (b *Bot) SetHandlers(h Handler, jh JoinHandler, rh ReactionHandler) {
+	b.handlers.Store(&handlerSet{message: h, join: jh, reaction: rh})
+}
+
+func (b *Bot) currentHandlers() *handlerSet {
+	return b.handlers.Load().(*handlerSet)
+}
+
+// Init must be called before anything else in order to initialize the bot.
+//
+// Events are received until ctx is done, Shutdown then waits for the
+// handlers still running.
 func (b *Bot) Init(ctx context.Context) error {
-	span := trace.FromContext(ctx).NewChild("Bot.Init")
-	defer span.Finish()
+	ctx, span := StartSpan(ctx, "Bot.Init")
+	defer span.End()
 
 	b.logf("Determining bot ID")
 
-	ai, err := b.slack.AuthTestContext(ctx)
+	ai, err := b.transport.AuthTest(ctx)
 	if err != nil {
 		return fmt.Errorf("retrieving bot user info: %v", err)
 	}
@@ -104,46 +200,162 @@ func (b *Bot) Init(ctx context.Context) error {
 
 	b.logf("Initialized %s with ID (%q) and msgprefix (%q) \n", b.name, b.id, b.msgprefix)
 
-	go b.handleEvents()
+	events := b.transport.Events(ctx)
+	b.received = make(chan struct{})
+	go func() {
+		defer close(b.received)
+		b.handleEvents(ctx, events)
+	}()
 
 	return nil
 }
 
-func (b *Bot) handleEvents() {
-	rtm := b.slack.NewRTM()
-	go rtm.ManageConnection()
+// handleEvents dispatches events until ctx is done, then the events already
+// received, such as Events API requests which were acknowledged, so Shutdown
+// waits for them.
+func (b *Bot) handleEvents(ctx context.Context, events <-chan slack.RTMEvent) {
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case msg := <-events:
+					b.handleEvent(msg)
+				default:
+					return
+				}
+			}
+		case msg := <-events:
+			b.handleEvent(msg)
+		}
+	}
+}
+
+func (b *Bot) handleEvent(msg slack.RTMEvent) {
+	b.reportEvent(msg)
+
+	switch message := msg.Data.(type) {
+	case *slack.MessageEvent:
+		b.dispatch("message", message.Channel, func() { b.handleMessage(message) })
+
+	case *slack.TeamJoinEvent:
+		b.dispatch("team_join", message.User.ID, func() { b.handleTeamJoin(message) })
 
-	for msg := range rtm.IncomingEvents {
-		switch message := msg.Data.(type) {
-		case *slack.MessageEvent:
-			go b.handleMessage(message)
+	case *slack.ReactionAddedEvent:
+		b.dispatch("reaction_added", message.Item.Channel, func() { b.handleReaction(message) })
 
-		case *slack.TeamJoinEvent:
-			go b.handleTeamJoin(message)
+	case *slack.SlashCommand:
+		responseType := b.slashResponseType
+		b.dispatch("slash_command", message.ChannelID, func() { b.handleSlashCommand(*message, responseType) })
+
+	case *Interaction:
+		ih := b.interactions
+		if ih == nil {
+			b.logf("ignoring %s interaction, no InteractionHandler is set\n", message.Type)
+			return
 		}
+		b.dispatch("interaction", message.Channel.ID, func() { b.handleInteraction(ih, *message) })
 	}
 }
 
 // handleTeamJoin is called when the someone joins the team
 func (b *Bot) handleTeamJoin(event *slack.TeamJoinEvent) {
-	span := b.trace.NewSpan("Bot.TeamJoined")
-	defer span.Finish()
+	jh := b.currentHandlers().join
+	if jh == nil {
+		return
+	}
 
-	ctx := trace.NewContext(context.Background(), span)
+	ctx, span := StartSpan(b.base, "Bot.TeamJoined")
+	defer span.End()
 
 	responder := joinResponder{b: b, event: event}
-	b.joinHandler.Handle(ctx, event, responder)
+	jh.Handle(ctx, event, responder)
+}
+
+// handleReaction is called when someone adds a reaction to a message
+func (b *Bot) handleReaction(event *slack.ReactionAddedEvent) {
+	rh := b.currentHandlers().reaction
+	if rh == nil || event.User == b.id {
+		return
+	}
+
+	ctx, span := StartSpan(b.base, "Bot.HandleReaction", attribute.String("reaction", event.Reaction))
+	defer span.End()
+
+	r := Reaction{Event: event}
+	if reply := b.replies.find(event.Item.Channel, event.Item.Timestamp); reply != nil {
+		r.Reply = reply
+		r.Author = reply.author
+	}
+	rh.Handle(ctx, r)
 }
 
 // handleMessage will process the incoming message and respond appropriately
 func (b *Bot) handleMessage(event *slack.MessageEvent) {
+	switch event.SubType {
+	case "message_changed":
+		b.handleMessageChanged(event)
+		return
+	case "message_deleted":
+		b.handleMessageDeleted(event)
+		return
+	}
+
+	b.processMessage(event, nil)
+}
+
+// handleMessageChanged runs the Handler for the new version of an edited
+// message the bot replied to. Responses replace the replies to the previous
+// version, replies which are no longer sent are deleted. Edits don't get new
+// replies, reactions or ephemeral responses, which were already sent for the
+// previous version.
+func (b *Bot) handleMessageChanged(event *slack.MessageEvent) {
+	if event.SubMessage == nil {
+		return
+	}
+	// Unfurling links changes messages without changing their text.
+	if event.PreviousMessage != nil && event.PreviousMessage.Text == event.SubMessage.Text {
+		return
+	}
+
+	edited := &slack.MessageEvent{Msg: *event.SubMessage}
+	edited.Channel = event.Channel
+	if edited.BotID != "" || edited.User == "" || edited.User == b.id {
+		return
+	}
+
+	replies := b.replies.take(messageRef{edited.Channel, edited.Timestamp})
+	if len(replies) == 0 {
+		return
+	}
+	edits := &pendingReplies{replies: replies}
+	b.processMessage(edited, edits)
+
+	for _, reply := range edits.rest() {
+		if err := reply.Delete(b.base); err != nil {
+			b.logf("deleting reply to edited message: %s\n", err)
+		}
+	}
+}
+
+// handleMessageDeleted deletes the replies to a deleted message.
+func (b *Bot) handleMessageDeleted(event *slack.MessageEvent) {
+	for _, reply := range b.replies.take(messageRef{event.Channel, event.DeletedTimestamp}) {
+		if err := reply.Delete(b.base); err != nil {
+			b.logf("deleting reply to deleted message: %s\n", err)
+		}
+	}
+}
+
+// processMessage passes the message to the Handler. Responses replace the
+// replies in edits, if any.
+func (b *Bot) processMessage(event *slack.MessageEvent, edits *pendingReplies) {
 	if event.BotID != "" || event.User == "" || event.SubType == "bot_message" {
 		return
 	}
 
-	span := b.trace.NewSpan("Bot.HandleMessage")
-	span.SetLabel("eventText", event.Text)
-	defer span.Finish()
+	ctx, span := StartSpan(b.base, "Bot.HandleMessage", attribute.String("eventText", event.Text))
+	defer span.End()
 
 	trimmedText := strings.TrimSpace(strings.ToLower(event.Text))
 
@@ -160,7 +372,6 @@ func (b *Bot) handleMessage(event *slack.MessageEvent) {
 		b.logf("channel: %s -> message: %q\n", event.Channel, trimmedText)
 	}
 
-	ctx := trace.NewContext(context.Background(), span)
 	m := Message{
 		Event:         event,
 		TrimmedText:   trimmedText,
@@ -169,9 +380,10 @@ func (b *Bot) handleMessage(event *slack.MessageEvent) {
 	r := responder{
 		bot:   b,
 		event: event,
+		edits: edits,
 	}
 
-	b.handler.Handle(ctx, m, r)
+	b.currentHandlers().message.Handle(ctx, m, r)
 }
 
 func (b *Bot) isBotMessage(event *slack.MessageEvent, eventText string) bool {
@@ -192,74 +404,146 @@ func (b *Bot) trimBot(msg string) string {
 //
 // Links and media is not unfurled.
 func (b *Bot) PostMessage(ctx context.Context, channel, text string, opts ...slack.MsgOption) error {
-	opts = append(opts,
+	_, _, err := b.transport.PostMessage(ctx, channel, messageOptions(text, opts...)...)
+	return err
+}
+
+// messageOptions appends the options for sending text as the bot user
+// without unfurling links and media to opts.
+func messageOptions(text string, opts ...slack.MsgOption) []slack.MsgOption {
+	return append(opts,
 		slack.MsgOptionAsUser(true),
 		slack.MsgOptionDisableLinkUnfurl(),
 		slack.MsgOptionDisableMediaUnfurl(),
 		slack.MsgOptionPostMessageParameters(slack.PostMessageParameters{LinkNames: 1}),
 		slack.MsgOptionText(text, false),
 	)
-	_, _, err := b.slack.PostMessageContext(ctx, channel, opts...)
-	return err
 }
 
 type responder struct {
 	bot   *Bot
 	event *slack.MessageEvent
+
+	// edits holds the replies to the previous version of an edited
+	// message. They are replaced by the responses to the new version,
+	// other responses and reactions are skipped.
+	edits *pendingReplies
 }
 
-func (r responder) Respond(ctx context.Context, msg string) {
+// post sends a message to channel and records it as a reply to the event.
+func (r responder) post(ctx context.Context, channel string, opts ...slack.MsgOption) *Reply {
+	reply := r.edits.next(channel)
+	if reply == nil && r.edits != nil {
+		return nil
+	}
+	if reply != nil {
+		err := r.bot.transport.UpdateMessage(ctx, reply.channel, reply.timestamp, opts...)
+		if err != nil {
+			r.bot.logf("%s\n", err)
+			return nil
+		}
+	} else {
+		c, ts, err := r.bot.transport.PostMessage(ctx, channel, opts...)
+		if err != nil {
+			r.bot.logf("%s\n", err)
+			return nil
+		}
+		reply = &Reply{bot: r.bot, to: channel, channel: c, timestamp: ts}
+	}
+	reply.author = r.event.User
+
+	r.bot.replies.add(messageRef{r.event.Channel, r.event.Timestamp}, reply)
+	return reply
+}
+
+func (r responder) Respond(ctx context.Context, msg string) *Reply {
 	if r.bot.devMode {
 		r.bot.logf("should reply to message %s with %s\n", r.event.Text, msg)
 	}
-	err := r.bot.PostMessage(ctx, r.event.Channel, msg,
+	return r.post(ctx, r.event.Channel, messageOptions(msg,
 		slack.MsgOptionTS(r.event.ThreadTimestamp),
-	)
-	if err != nil {
-		r.bot.logf("%s\n", err)
-	}
+	)...)
 }
 
-func (r responder) RespondUnfurled(ctx context.Context, msg string) {
+func (r responder) RespondUnfurled(ctx context.Context, msg string) *Reply {
 	if r.bot.devMode {
 		r.bot.logf("should reply to message %s with %s\n", r.event.Text, msg)
 	}
-	_, _, err := r.bot.slack.PostMessageContext(ctx, r.event.Channel,
+	return r.post(ctx, r.event.Channel,
 		slack.MsgOptionAsUser(true),
 		slack.MsgOptionTS(r.event.ThreadTimestamp),
 		slack.MsgOptionEnableLinkUnfurl(),
 		slack.MsgOptionText(msg, false),
 	)
-	if err != nil {
-		r.bot.logf("%s\n", err)
-	}
 }
 
-func (r responder) RespondWithAttachment(ctx context.Context, msg, attachement string) {
-	r.bot.PostMessage(ctx, r.event.Channel, msg,
+func (r responder) RespondWithAttachment(ctx context.Context, msg, attachement string) *Reply {
+	return r.post(ctx, r.event.Channel, messageOptions(msg,
 		slack.MsgOptionAttachments(slack.Attachment{Text: attachement}),
-	)
+	)...)
 }
 
-func (r responder) RespondPrivate(ctx context.Context, msg string) {
-	r.bot.PostMessage(ctx, r.event.User, msg)
+func (r responder) RespondPrivate(ctx context.Context, msg string) *Reply {
+	return r.post(ctx, r.event.User, messageOptions(msg)...)
 }
 
-func (r responder) RespondPrivateWithAttachment(ctx context.Context, msg, attachement string) {
-	r.bot.PostMessage(ctx, r.event.User, msg,
+func (r responder) RespondPrivateWithAttachment(ctx context.Context, msg, attachement string) *Reply {
+	return r.post(ctx, r.event.User, messageOptions(msg,
 		slack.MsgOptionAttachments(slack.Attachment{Text: attachement}),
-	)
+	)...)
+}
+
+func (r responder) RespondWithBlocks(ctx context.Context, msg string, blocks ...slack.Block) *Reply {
+	return r.post(ctx, r.event.Channel, messageOptions(msg,
+		slack.MsgOptionTS(r.event.ThreadTimestamp),
+		slack.MsgOptionBlocks(blocks...),
+	)...)
+}
+
+func (r responder) RespondPrivateWithBlocks(ctx context.Context, msg string, blocks ...slack.Block) *Reply {
+	return r.post(ctx, r.event.User, messageOptions(msg,
+		slack.MsgOptionBlocks(blocks...),
+	)...)
+}
+
+func (r responder) RespondEphemeral(ctx context.Context, msg string, blocks ...slack.Block) *Reply {
+	if r.bot.devMode {
+		r.bot.logf("should reply ephemerally to message %s with %s\n", r.event.Text, msg)
+	}
+	if r.edits != nil {
+		return nil
+	}
+	opts := []slack.MsgOption{
+		slack.MsgOptionText(msg, false),
+		slack.MsgOptionTS(r.event.ThreadTimestamp),
+		slack.MsgOptionDisableLinkUnfurl(),
+	}
+	if len(blocks) > 0 {
+		opts = append(opts, slack.MsgOptionBlocks(blocks...))
+	}
+
+	// Ephemeral messages can't be edited.
+	err := r.bot.transport.PostEphemeral(ctx, r.event.Channel, r.event.User, opts...)
+	if err == nil {
+		return nil
+	}
+	r.bot.logf("posting ephemeral message, falling back to direct message: %s\n", err)
+
+	return r.post(ctx, r.event.User, messageOptions(msg, slack.MsgOptionBlocks(blocks...))...)
 }
 
 func (r responder) React(ctx context.Context, reaction string) {
 	if r.bot.devMode {
 		r.bot.logf("should reply to message %s with %s\n", r.event.Text, reaction)
 	}
+	if r.edits != nil {
+		return
+	}
 	item := slack.ItemRef{
 		Channel:   r.event.Channel,
 		Timestamp: r.event.Timestamp,
 	}
-	err := r.bot.slack.AddReactionContext(ctx, reaction, item)
+	err := r.bot.transport.AddReaction(ctx, reaction, item)
 	if err != nil {
 		r.bot.logf("%s\n", err)
 		return
@@ -0,0 +1,465 @@
+// Package bottest provides an in-memory bot.Transport for testing handlers
+// end to end without connecting to Slack.
+package bottest
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gobridge/gopher/bot"
+	"github.com/nlopes/slack"
+)
+
+var _ bot.Transport = (*Transport)(nil)
+
+// Message is a message posted through the Transport.
+type Message struct {
+	Channel         string
+	Text            string
+	ThreadTimestamp string
+	Timestamp       string
+	Attachments     []slack.Attachment
+	Blocks          []slack.Block
+
+	// EphemeralUser is set for messages sent with PostEphemeral.
+	EphemeralUser string
+
+	// Edited and Deleted are set for messages changed with UpdateMessage
+	// and DeleteMessage.
+	Edited  bool
+	Deleted bool
+
+	// ResponseURL, ResponseType, ReplaceOriginal and DeleteOriginal are
+	// set for messages sent with PostResponse.
+	ResponseURL     string
+	ResponseType    string
+	ReplaceOriginal bool
+	DeleteOriginal  bool
+}
+
+// View is a modal opened through the Transport.
+type View struct {
+	TriggerID string
+	View      bot.View
+}
+
+// Reaction is a reaction added through the Transport.
+type Reaction struct {
+	Name      string
+	Channel   string
+	Timestamp string
+}
+
+// Transport is an in-memory implementation of bot.Transport.
+//
+// Events are injected with Send, SendMessage, SendEdit, SendDelete,
+// SendReaction and SendTeamJoin. Everything the bot sends is recorded and can be inspected
+// with Messages, Reactions and Views.
+type Transport struct {
+	// UserID and UserName are returned by AuthTest.
+	UserID   string
+	UserName string
+
+	// Files are returned by GetFileInfo, keyed by file ID.
+	Files map[string]*slack.File
+	// FileContents are returned by GetFile, keyed by download URL.
+	FileContents map[string]string
+
+	// EphemeralErr is returned by PostEphemeral when set.
+	EphemeralErr error
+
+	events chan slack.RTMEvent
+
+	mu        sync.Mutex
+	changed   chan struct{}
+	clock     int
+	messages  []Message
+	reactions []Reaction
+	views     []View
+}
+
+// NewTransport creates a Transport for a bot user with userID and userName.
+func NewTransport(userID, userName string) *Transport {
+	return &Transport{
+		UserID:       userID,
+		UserName:     userName,
+		Files:        make(map[string]*slack.File),
+		FileContents: make(map[string]string),
+		events:       make(chan slack.RTMEvent, 50),
+		changed:      make(chan struct{}),
+	}
+}
+
+// AuthTest implements bot.Transport.
+func (t *Transport) AuthTest(ctx context.Context) (*slack.AuthTestResponse, error) {
+	return &slack.AuthTestResponse{
+		UserID: t.UserID,
+		User:   t.UserName,
+	}, nil
+}
+
+// PostMessage implements bot.Transport.
+func (t *Transport) PostMessage(ctx context.Context, channel string, opts ...slack.MsgOption) (string, string, error) {
+	m, err := decodeMessage(channel, opts)
+	if err != nil {
+		return "", "", err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	m.Timestamp = t.timestamp()
+	t.messages = append(t.messages, m)
+	t.notify()
+
+	return channel, m.Timestamp, nil
+}
+
+// UpdateMessage implements bot.Transport.
+func (t *Transport) UpdateMessage(ctx context.Context, channel, timestamp string, opts ...slack.MsgOption) error {
+	u, err := decodeMessage(channel, opts)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	m := t.find(channel, timestamp)
+	if m == nil {
+		return errors.New("message_not_found")
+	}
+	m.Text = u.Text
+	m.Attachments = u.Attachments
+	m.Blocks = u.Blocks
+	m.Edited = true
+	t.notify()
+
+	return nil
+}
+
+// DeleteMessage implements bot.Transport. Deleted messages are kept and
+// marked as Deleted.
+func (t *Transport) DeleteMessage(ctx context.Context, channel, timestamp string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	m := t.find(channel, timestamp)
+	if m == nil {
+		return errors.New("message_not_found")
+	}
+	m.Deleted = true
+	t.notify()
+
+	return nil
+}
+
+// find returns the posted message with timestamp in channel, or nil. The
+// caller must hold t.mu.
+func (t *Transport) find(channel, timestamp string) *Message {
+	for i := range t.messages {
+		m := &t.messages[i]
+		if m.Channel == channel && m.Timestamp == timestamp && !m.Deleted {
+			return m
+		}
+	}
+	return nil
+}
+
+// PostEphemeral implements bot.Transport.
+func (t *Transport) PostEphemeral(ctx context.Context, channel, user string, opts ...slack.MsgOption) error {
+	if t.EphemeralErr != nil {
+		return t.EphemeralErr
+	}
+
+	m, err := decodeMessage(channel, opts)
+	if err != nil {
+		return err
+	}
+	m.EphemeralUser = user
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.messages = append(t.messages, m)
+	t.notify()
+
+	return nil
+}
+
+// decodeMessage converts message options to a Message.
+func decodeMessage(channel string, opts []slack.MsgOption) (Message, error) {
+	_, values, err := slack.UnsafeApplyMsgOptions("", channel, "", opts...)
+	if err != nil {
+		return Message{}, err
+	}
+
+	m := Message{
+		Channel:         channel,
+		Text:            values.Get("text"),
+		ThreadTimestamp: values.Get("thread_ts"),
+	}
+	if a := values.Get("attachments"); a != "" {
+		if err := json.Unmarshal([]byte(a), &m.Attachments); err != nil {
+			return m, fmt.Errorf("decoding attachments: %v", err)
+		}
+	}
+	if b := values.Get("blocks"); b != "" {
+		var blocks slack.Blocks
+		if err := json.Unmarshal([]byte(b), &blocks); err != nil {
+			return m, fmt.Errorf("decoding blocks: %v", err)
+		}
+		m.Blocks = blocks.BlockSet
+	}
+
+	return m, nil
+}
+
+// PostResponse implements bot.Transport.
+func (t *Transport) PostResponse(ctx context.Context, responseURL string, msg slack.Msg) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.messages = append(t.messages, Message{
+		Text:            msg.Text,
+		Attachments:     msg.Attachments,
+		Blocks:          msg.Blocks.BlockSet,
+		ResponseURL:     responseURL,
+		ResponseType:    msg.ResponseType,
+		ReplaceOriginal: msg.ReplaceOriginal,
+		DeleteOriginal:  msg.DeleteOriginal,
+	})
+	t.notify()
+
+	return nil
+}
+
+// OpenView implements bot.Transport.
+func (t *Transport) OpenView(ctx context.Context, triggerID string, view bot.View) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.views = append(t.views, View{TriggerID: triggerID, View: view})
+	t.notify()
+
+	return nil
+}
+
+// AddReaction implements bot.Transport.
+func (t *Transport) AddReaction(ctx context.Context, reaction string, item slack.ItemRef) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.reactions = append(t.reactions, Reaction{
+		Name:      reaction,
+		Channel:   item.Channel,
+		Timestamp: item.Timestamp,
+	})
+	t.notify()
+
+	return nil
+}
+
+// GetFileInfo implements bot.Transport.
+func (t *Transport) GetFileInfo(ctx context.Context, fileID string) (*slack.File, error) {
+	f, ok := t.Files[fileID]
+	if !ok {
+		return nil, fmt.Errorf("file_not_found")
+	}
+	return f, nil
+}
+
+// GetFile implements bot.Transport.
+func (t *Transport) GetFile(ctx context.Context, downloadURL string, w io.Writer) error {
+	contents, ok := t.FileContents[downloadURL]
+	if !ok {
+		return fmt.Errorf("file_not_found")
+	}
+	_, err := io.Copy(w, strings.NewReader(contents))
+	return err
+}
+
+// Events implements bot.Transport.
+func (t *Transport) Events(ctx context.Context) <-chan slack.RTMEvent {
+	return t.events
+}
+
+// Send delivers an arbitrary event to the bot.
+func (t *Transport) Send(event slack.RTMEvent) {
+	t.events <- event
+}
+
+// SendMessage delivers a message from user in channel to the bot and
+// returns the message timestamp.
+func (t *Transport) SendMessage(channel, user, text string) string {
+	t.mu.Lock()
+	ts := t.timestamp()
+	t.mu.Unlock()
+
+	t.Send(slack.RTMEvent{
+		Type: "message",
+		Data: &slack.MessageEvent{
+			Msg: slack.Msg{
+				Type:      "message",
+				Channel:   channel,
+				User:      user,
+				Text:      text,
+				Timestamp: ts,
+			},
+		},
+	})
+
+	return ts
+}
+
+// SendEdit delivers an event for user editing the message with timestamp ts
+// in channel to text.
+func (t *Transport) SendEdit(channel, user, ts, text string) {
+	t.Send(slack.RTMEvent{
+		Type: "message",
+		Data: &slack.MessageEvent{
+			Msg: slack.Msg{
+				Type:    "message",
+				SubType: "message_changed",
+				Channel: channel,
+				Hidden:  true,
+			},
+			SubMessage: &slack.Msg{
+				Type:      "message",
+				User:      user,
+				Text:      text,
+				Timestamp: ts,
+			},
+		},
+	})
+}
+
+// SendDelete delivers an event for the message with timestamp ts in channel
+// being deleted.
+func (t *Transport) SendDelete(channel, ts string) {
+	t.Send(slack.RTMEvent{
+		Type: "message",
+		Data: &slack.MessageEvent{
+			Msg: slack.Msg{
+				Type:             "message",
+				SubType:          "message_deleted",
+				Channel:          channel,
+				Hidden:           true,
+				DeletedTimestamp: ts,
+			},
+		},
+	})
+}
+
+// SendReaction delivers an event for user reacting with reaction to the
+// message with timestamp ts in channel.
+func (t *Transport) SendReaction(channel, ts, user, reaction string) {
+	event := &slack.ReactionAddedEvent{
+		Type:     "reaction_added",
+		User:     user,
+		Reaction: reaction,
+	}
+	event.Item.Type = "message"
+	event.Item.Channel = channel
+	event.Item.Timestamp = ts
+
+	t.Send(slack.RTMEvent{Type: "reaction_added", Data: event})
+}
+
+// SendTeamJoin delivers a team join event for user to the bot.
+func (t *Transport) SendTeamJoin(user slack.User) {
+	t.Send(slack.RTMEvent{
+		Type: "team_join",
+		Data: &slack.TeamJoinEvent{
+			Type: "team_join",
+			User: user,
+		},
+	})
+}
+
+// Messages returns a copy of the messages posted so far.
+func (t *Transport) Messages() []Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]Message(nil), t.messages...)
+}
+
+// Reactions returns a copy of the reactions added so far.
+func (t *Transport) Reactions() []Reaction {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]Reaction(nil), t.reactions...)
+}
+
+// Views returns a copy of the modals opened so far.
+func (t *Transport) Views() []View {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]View(nil), t.views...)
+}
+
+// Wait blocks until cond returns true or timeout elapses. cond is evaluated
+// every time the bot posts a message, adds a reaction or opens a modal.
+func (t *Transport) Wait(timeout time.Duration, cond func(*Transport) bool) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		t.mu.Lock()
+		changed := t.changed
+		t.mu.Unlock()
+
+		if cond(t) {
+			return true
+		}
+
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return cond(t)
+		}
+	}
+}
+
+// timestamp returns a unique, increasing Slack style timestamp.
+// t.mu must be held.
+func (t *Transport) timestamp() string {
+	t.clock++
+	return fmt.Sprintf("%d.%06d", 1500000000+t.clock, 0)
+}
+
+// notify wakes up callers of Wait. t.mu must be held.
+func (t *Transport) notify() {
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+// SignedRequest creates a POST request to path with body, signed with secret
+// the way Slack signs requests to slash command, interactivity and Events API
+// endpoints.
+func SignedRequest(secret, path, body string) *http.Request {
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + ts + ":" + body))
+
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Slack-Request-Timestamp", ts)
+	req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return req
+}
@@ -0,0 +1,102 @@
+package bot
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/nlopes/slack"
+)
+
+// A Consumer is a Handler which reports whether it consumed a message, for
+// handlers that combine other handlers and stop at the first one consuming
+// the message.
+//
+// Handlers which aren't Consumers consume a message when they respond or
+// react to it.
+type Consumer interface {
+	Handler
+	Consume(context.Context, Message, Responder) bool
+}
+
+// ConsumerFunc adapts a function to be a Consumer.
+type ConsumerFunc func(context.Context, Message, Responder) bool
+
+// Handle calls f(ctx, m, r).
+func (f ConsumerFunc) Handle(ctx context.Context, m Message, r Responder) {
+	f(ctx, m, r)
+}
+
+// Consume calls f(ctx, m, r).
+func (f ConsumerFunc) Consume(ctx context.Context, m Message, r Responder) bool {
+	return f(ctx, m, r)
+}
+
+// Consume calls h and reports whether it consumed m.
+func Consume(ctx context.Context, h Handler, m Message, r Responder) bool {
+	if c, ok := h.(Consumer); ok {
+		return c.Consume(ctx, m, r)
+	}
+
+	tr := &trackingResponder{Responder: r}
+	h.Handle(ctx, m, tr)
+	return tr.responded()
+}
+
+// trackingResponder records whether any of its methods were called.
+type trackingResponder struct {
+	Responder
+	calls int32
+}
+
+func (r *trackingResponder) responded() bool {
+	return atomic.LoadInt32(&r.calls) > 0
+}
+
+func (r *trackingResponder) track() {
+	atomic.AddInt32(&r.calls, 1)
+}
+
+func (r *trackingResponder) Respond(ctx context.Context, msg string) *Reply {
+	r.track()
+	return r.Responder.Respond(ctx, msg)
+}
+
+func (r *trackingResponder) RespondUnfurled(ctx context.Context, msg string) *Reply {
+	r.track()
+	return r.Responder.RespondUnfurled(ctx, msg)
+}
+
+func (r *trackingResponder) RespondWithAttachment(ctx context.Context, msg, attachment string) *Reply {
+	r.track()
+	return r.Responder.RespondWithAttachment(ctx, msg, attachment)
+}
+
+func (r *trackingResponder) RespondPrivate(ctx context.Context, msg string) *Reply {
+	r.track()
+	return r.Responder.RespondPrivate(ctx, msg)
+}
+
+func (r *trackingResponder) RespondPrivateWithAttachment(ctx context.Context, msg, attachment string) *Reply {
+	r.track()
+	return r.Responder.RespondPrivateWithAttachment(ctx, msg, attachment)
+}
+
+func (r *trackingResponder) RespondWithBlocks(ctx context.Context, msg string, blocks ...slack.Block) *Reply {
+	r.track()
+	return r.Responder.RespondWithBlocks(ctx, msg, blocks...)
+}
+
+func (r *trackingResponder) RespondPrivateWithBlocks(ctx context.Context, msg string, blocks ...slack.Block) *Reply {
+	r.track()
+	return r.Responder.RespondPrivateWithBlocks(ctx, msg, blocks...)
+}
+
+func (r *trackingResponder) RespondEphemeral(ctx context.Context, msg string, blocks ...slack.Block) *Reply {
+	r.track()
+	return r.Responder.RespondEphemeral(ctx, msg, blocks...)
+}
+
+func (r *trackingResponder) React(ctx context.Context, reaction string) {
+	r.track()
+	r.Responder.React(ctx, reaction)
+}
@@ -0,0 +1,222 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+// seenEventsSize is the number of event IDs remembered to drop retried
+// deliveries.
+const seenEventsSize = 1000
+
+// eventsAPIQueueTimeout is how long a request waits for room in the queue of
+// events before it's answered with an error, for Slack to retry it. Slack
+// expects an answer within 3 seconds.
+const eventsAPIQueueTimeout = 2 * time.Second
+
+// EventsAPI is an EventSource which receives events from the Slack Events API.
+//
+// It must be registered as the HTTP handler for the Request URL configured
+// for the Slack app.
+//
+// Events which can't be queued, because the queue stays full or the events
+// stopped being received, are answered with 503 Service Unavailable so Slack
+// retries them.
+//
+// https://api.slack.com/apis/connections/events-api
+type EventsAPI struct {
+	secret string
+	logf   Logger
+	events chan slack.RTMEvent
+	done   chan struct{}
+	seen   seenEvents
+}
+
+// NewEventsAPI creates an EventsAPI verifying requests with signingSecret.
+func NewEventsAPI(signingSecret string, log Logger) *EventsAPI {
+	return &EventsAPI{
+		secret: signingSecret,
+		logf:   log,
+		events: make(chan slack.RTMEvent, 50),
+		done:   make(chan struct{}),
+	}
+}
+
+// Events implements EventSource. Events are delivered until ctx is done,
+// the HTTP server serving e should be shut down first so requests in flight
+// are delivered.
+func (e *EventsAPI) Events(ctx context.Context) <-chan slack.RTMEvent {
+	go func() {
+		<-ctx.Done()
+		close(e.done)
+	}()
+	return e.events
+}
+
+// eventsAPIPayload is the outer structure of Events API requests.
+type eventsAPIPayload struct {
+	Type      string          `json:"type"`
+	Challenge string          `json:"challenge"`
+	EventID   string          `json:"event_id"`
+	Event     json.RawMessage `json:"event"`
+}
+
+// ServeHTTP handles Events API requests from Slack.
+func (e *EventsAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.NotFound(w, r)
+		return
+	}
+
+	body, err := readVerifiedBody(r, e.secret)
+	if err != nil {
+		e.logf("rejecting events API request: %v\n", err)
+		http.Error(w, "invalid request signature", http.StatusUnauthorized)
+		return
+	}
+
+	var payload eventsAPIPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		e.logf("unmarshaling events API request: %v\n", err)
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	switch payload.Type {
+	case "url_verification":
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(payload.Challenge))
+		return
+
+	case "event_callback":
+		select {
+		case <-e.done:
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+			return
+		default:
+		}
+
+		// Slack retries deliveries it considers failed, setting
+		// X-Slack-Retry-Num. Retries carry the same event ID.
+		if !e.seen.add(payload.EventID) {
+			e.logf("dropping retried event %s (attempt %s)\n", payload.EventID, r.Header.Get("X-Slack-Retry-Num"))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		event, ok, err := decodeEvent(payload.Event)
+		if err != nil {
+			e.logf("unmarshaling event %s: %v\n", payload.EventID, err)
+			http.Error(w, "invalid event", http.StatusBadRequest)
+			return
+		}
+		if ok {
+			timeout := time.NewTimer(eventsAPIQueueTimeout)
+			defer timeout.Stop()
+
+			select {
+			case e.events <- event:
+			case <-e.done:
+				e.seen.forget(payload.EventID)
+				http.Error(w, "shutting down", http.StatusServiceUnavailable)
+				return
+			case <-timeout.C:
+				e.seen.forget(payload.EventID)
+				e.logf("event queue full, asking Slack to retry event %s\n", payload.EventID)
+				http.Error(w, "event queue full", http.StatusServiceUnavailable)
+				return
+			case <-r.Context().Done():
+				e.seen.forget(payload.EventID)
+				return
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// seenEvents remembers recently delivered event IDs.
+type seenEvents struct {
+	mu   sync.Mutex
+	seen map[string]bool
+	ids  []string // oldest first
+}
+
+// add records id and reports whether it was not seen before.
+func (s *seenEvents) add(id string) bool {
+	if id == "" {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.seen[id] {
+		return false
+	}
+	if s.seen == nil {
+		s.seen = make(map[string]bool)
+	}
+
+	if len(s.ids) == seenEventsSize {
+		delete(s.seen, s.ids[0])
+		s.ids = s.ids[1:]
+	}
+	s.ids = append(s.ids, id)
+	s.seen[id] = true
+
+	return true
+}
+
+// forget removes id so a retried delivery will be processed.
+func (s *seenEvents) forget(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.seen[id] {
+		return
+	}
+	delete(s.seen, id)
+	// The id was usually just added, so search from the newest.
+	for i := len(s.ids) - 1; i >= 0; i-- {
+		if s.ids[i] == id {
+			s.ids = append(s.ids[:i], s.ids[i+1:]...)
+			break
+		}
+	}
+}
+
+// decodeEvent converts the inner event of an Events API or Socket Mode
+// payload to the slack type dispatched by the Bot. ok is false for event types
+// the Bot doesn't handle.
+func decodeEvent(raw json.RawMessage) (event slack.RTMEvent, ok bool, err error) {
+	var inner struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(raw, &inner); err != nil {
+		return event, false, err
+	}
+
+	var data interface{}
+	switch inner.Type {
+	case "message":
+		data = &slack.MessageEvent{}
+	case "team_join":
+		data = &slack.TeamJoinEvent{}
+	case "reaction_added":
+		data = &slack.ReactionAddedEvent{}
+	default:
+		return event, false, nil
+	}
+
+	if err := json.Unmarshal(raw, data); err != nil {
+		return event, false, err
+	}
+
+	return slack.RTMEvent{Type: inner.Type, Data: data}, true, nil
+}
@@ -0,0 +1,110 @@
+package bot_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gobridge/gopher/bot"
+	"github.com/gobridge/gopher/bot/bottest"
+	"github.com/nlopes/slack"
+)
+
+const testSecret = "8f742231b10e8888abcd99yyyzzz85a5"
+
+func nopLog(string, ...interface{}) {}
+
+func TestEventsAPI(t *testing.T) {
+	t.Run("rejects invalid signature", func(t *testing.T) {
+		e := bot.NewEventsAPI(testSecret, nopLog)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, bottest.SignedRequest("wrong", "/slack/events", `{"type":"url_verification","challenge":"abc"}`))
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected: %d\nactual: %d", http.StatusUnauthorized, w.Code)
+		}
+	})
+
+	t.Run("answers url verification", func(t *testing.T) {
+		e := bot.NewEventsAPI(testSecret, nopLog)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, bottest.SignedRequest(testSecret, "/slack/events", `{"type":"url_verification","challenge":"abc"}`))
+
+		if w.Code != http.StatusOK || w.Body.String() != "abc" {
+			t.Errorf("expected: 200 %q\nactual: %d %q", "abc", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("delivers message once", func(t *testing.T) {
+		e := bot.NewEventsAPI(testSecret, nopLog)
+		body := `{"type":"event_callback","event_id":"Ev1","event":{"type":"message","channel":"C1","user":"U1","text":"hello","ts":"1.0"}}`
+
+		for i := 0; i < 2; i++ {
+			w := httptest.NewRecorder()
+			req := bottest.SignedRequest(testSecret, "/slack/events", body)
+			if i > 0 {
+				req.Header.Set("X-Slack-Retry-Num", strconv.Itoa(i))
+			}
+			e.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected: %d\nactual: %d", http.StatusOK, w.Code)
+			}
+		}
+
+		events := e.Events(context.Background())
+		if len(events) != 1 {
+			t.Fatalf("expected 1 event, got %d", len(events))
+		}
+		msg, ok := (<-events).Data.(*slack.MessageEvent)
+		if !ok {
+			t.Fatalf("expected *slack.MessageEvent")
+		}
+		if msg.Channel != "C1" || msg.User != "U1" || msg.Text != "hello" {
+			t.Errorf("unexpected message: %#v", msg.Msg)
+		}
+	})
+
+	t.Run("asks to retry once events stopped", func(t *testing.T) {
+		e := bot.NewEventsAPI(testSecret, nopLog)
+		ctx, cancel := context.WithCancel(context.Background())
+		events := e.Events(ctx)
+
+		// Fill the queue, so the next event waits until events stop.
+		for i := 0; i < cap(events); i++ {
+			body := `{"type":"event_callback","event_id":"Ev` + strconv.Itoa(i) + `","event":{"type":"message","channel":"C1"}}`
+			e.ServeHTTP(httptest.NewRecorder(), bottest.SignedRequest(testSecret, "/slack/events", body))
+		}
+		body := `{"type":"event_callback","event_id":"EvLast","event":{"type":"message","channel":"C1"}}`
+		done := make(chan int)
+		go func() {
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, bottest.SignedRequest(testSecret, "/slack/events", body))
+			done <- w.Code
+		}()
+		cancel()
+
+		if code := <-done; code != http.StatusServiceUnavailable {
+			t.Errorf("expected: %d\nactual: %d", http.StatusServiceUnavailable, code)
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, bottest.SignedRequest(testSecret, "/slack/events", body))
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("expected the retry to be refused too, got %d", w.Code)
+		}
+		if len(events) != cap(events) {
+			t.Errorf("expected %d queued events, got %d", cap(events), len(events))
+		}
+	})
+
+	t.Run("ignores unknown events", func(t *testing.T) {
+		e := bot.NewEventsAPI(testSecret, nopLog)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, bottest.SignedRequest(testSecret, "/slack/events", `{"type":"event_callback","event_id":"Ev2","event":{"type":"pin_added"}}`))
+
+		if w.Code != http.StatusOK || len(e.Events(context.Background())) != 0 {
+			t.Errorf("expected 200 and no events, got %d and %d events", w.Code, len(e.Events(context.Background())))
+		}
+	})
+}
@@ -0,0 +1,183 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/nlopes/slack"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Interaction types passed to an InteractionHandler.
+const (
+	InteractionBlockActions   = "block_actions"
+	InteractionViewSubmission = "view_submission"
+	InteractionMessageAction  = "message_action" // message shortcut
+	InteractionShortcut       = "shortcut"       // global shortcut
+)
+
+// Interaction is a user interacting with a message or modal, such as clicking
+// a button, submitting a modal or running a shortcut.
+type Interaction struct {
+	Type        string `json:"type"`
+	CallbackID  string `json:"callback_id"` // shortcuts
+	TriggerID   string `json:"trigger_id"`  // used to open modals
+	ResponseURL string `json:"response_url"`
+	User        struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"user"`
+	Channel struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"channel"`
+
+	Message *slack.Message       `json:"message"` // originating message
+	Actions []*slack.BlockAction `json:"actions"` // block_actions
+	View    *View                `json:"view"`    // view_submission or actions within a modal
+}
+
+// View is a modal view.
+//
+// https://api.slack.com/reference/surfaces/views
+type View struct {
+	ID              string                 `json:"id,omitempty"`
+	Type            string                 `json:"type"`
+	CallbackID      string                 `json:"callback_id,omitempty"`
+	Title           *slack.TextBlockObject `json:"title,omitempty"`
+	Submit          *slack.TextBlockObject `json:"submit,omitempty"`
+	Close           *slack.TextBlockObject `json:"close,omitempty"`
+	Blocks          slack.Blocks           `json:"blocks"`
+	PrivateMetadata string                 `json:"private_metadata,omitempty"`
+
+	// State is set for submitted views. It maps block IDs to action IDs
+	// to the value of input elements.
+	State *struct {
+		Values map[string]map[string]slack.BlockAction `json:"values"`
+	} `json:"state,omitempty"`
+}
+
+// An InteractionHandler responds to an Interaction.
+type InteractionHandler interface {
+	Handle(context.Context, Interaction, InteractionResponder)
+}
+
+// InteractionHandlerFunc adapts a function to be an InteractionHandler.
+type InteractionHandlerFunc func(context.Context, Interaction, InteractionResponder)
+
+// Handle calls f(ctx, i, r).
+func (f InteractionHandlerFunc) Handle(ctx context.Context, i Interaction, r InteractionResponder) {
+	f(ctx, i, r)
+}
+
+// InteractionResponder provides methods for responding to interactions.
+type InteractionResponder interface {
+	// Respond sends a message only visible to the user.
+	Respond(ctx context.Context, msg string)
+	// UpdateMessage replaces the originating message.
+	UpdateMessage(ctx context.Context, msg string, blocks ...slack.Block)
+	// DeleteMessage deletes the originating message.
+	DeleteMessage(ctx context.Context)
+	// OpenModal opens view as a modal for the user.
+	OpenModal(ctx context.Context, view View)
+}
+
+// Interactions returns an http.Handler for Slack interactivity requests which
+// passes them to ih. It must be registered as the Request URL in the
+// Interactivity & Shortcuts settings of the Slack app.
+//
+// Requests are acknowledged before ih is called, so modals are closed on
+// submission.
+func (b *Bot) Interactions(signingSecret string, ih InteractionHandler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.NotFound(w, r)
+			return
+		}
+
+		body, err := readVerifiedBody(r, signingSecret)
+		if err != nil {
+			b.logf("rejecting interaction: %v\n", err)
+			http.Error(w, "invalid request signature", http.StatusUnauthorized)
+			return
+		}
+
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			b.logf("parsing interaction: %v\n", err)
+			http.Error(w, "invalid payload", http.StatusBadRequest)
+			return
+		}
+
+		var i Interaction
+		if err := json.Unmarshal([]byte(form.Get("payload")), &i); err != nil {
+			b.logf("unmarshaling interaction: %v\n", err)
+			http.Error(w, "invalid payload", http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+
+		b.dispatch("interaction", i.Channel.ID, func() { b.handleInteraction(ih, i) })
+	})
+}
+
+// handleInteraction passes the interaction to ih.
+func (b *Bot) handleInteraction(ih InteractionHandler, i Interaction) {
+	ctx, span := StartSpan(b.base, "Bot.HandleInteraction", attribute.String("type", i.Type))
+	defer span.End()
+
+	if b.devMode {
+		b.logf("got interaction: %#v\n", i)
+	}
+
+	ih.Handle(ctx, i, interactionResponder{bot: b, interaction: i})
+}
+
+type interactionResponder struct {
+	bot         *Bot
+	interaction Interaction
+}
+
+func (r interactionResponder) respond(ctx context.Context, msg slack.Msg) {
+	if r.interaction.ResponseURL == "" {
+		r.bot.logf("can't respond to %s interaction without response_url\n", r.interaction.Type)
+		return
+	}
+
+	err := r.bot.transport.PostResponse(ctx, r.interaction.ResponseURL, msg)
+	if err != nil {
+		r.bot.logf("%s\n", err)
+	}
+}
+
+func (r interactionResponder) Respond(ctx context.Context, msg string) {
+	r.respond(ctx, slack.Msg{
+		Text:         msg,
+		ResponseType: slack.ResponseTypeEphemeral,
+	})
+}
+
+func (r interactionResponder) UpdateMessage(ctx context.Context, msg string, blocks ...slack.Block) {
+	r.respond(ctx, slack.Msg{
+		Text:            msg,
+		Blocks:          slack.Blocks{BlockSet: blocks},
+		ReplaceOriginal: true,
+	})
+}
+
+func (r interactionResponder) DeleteMessage(ctx context.Context) {
+	r.respond(ctx, slack.Msg{DeleteOriginal: true})
+}
+
+func (r interactionResponder) OpenModal(ctx context.Context, view View) {
+	if view.Type == "" {
+		view.Type = "modal"
+	}
+	err := r.bot.transport.OpenView(ctx, r.interaction.TriggerID, view)
+	if err != nil {
+		r.bot.logf("opening modal: %s\n", err)
+	}
+}
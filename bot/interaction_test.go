@@ -0,0 +1,60 @@
+package bot_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gobridge/gopher/bot"
+	"github.com/gobridge/gopher/bot/bottest"
+)
+
+func TestInteractions(t *testing.T) {
+	transport := bottest.NewTransport("UGOPHER", "gopher")
+	b := bot.New(transport, false, nopLog, nil, nil, nil)
+
+	ih := bot.InteractionHandlerFunc(func(ctx context.Context, i bot.Interaction, r bot.InteractionResponder) {
+		if i.Type != bot.InteractionBlockActions || len(i.Actions) != 1 || i.Actions[0].ActionID != "helpful" {
+			t.Errorf("unexpected interaction: %#v", i)
+			return
+		}
+		r.UpdateMessage(ctx, "Thanks for the feedback!")
+		r.OpenModal(ctx, bot.View{CallbackID: "feedback"})
+	})
+
+	payload := `{
+		"type": "block_actions",
+		"trigger_id": "T1",
+		"response_url": "https://hooks.slack.com/actions/1",
+		"user": {"id": "U1", "name": "gopher"},
+		"channel": {"id": "C1", "name": "general"},
+		"actions": [{"action_id": "helpful", "block_id": "B1", "type": "button", "value": "yes"}]
+	}`
+	body := url.Values{"payload": {payload}}.Encode()
+
+	w := httptest.NewRecorder()
+	b.Interactions(testSecret, ih).ServeHTTP(w, bottest.SignedRequest(testSecret, "/slack/interactions", body))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected: %d\nactual: %d", http.StatusOK, w.Code)
+	}
+
+	ok := transport.Wait(time.Second, func(tr *bottest.Transport) bool {
+		return len(tr.Messages()) == 1 && len(tr.Views()) == 1
+	})
+	if !ok {
+		t.Fatalf("expected 1 message and 1 view, got %#v and %#v", transport.Messages(), transport.Views())
+	}
+
+	msg := transport.Messages()[0]
+	if msg.Text != "Thanks for the feedback!" || !msg.ReplaceOriginal || msg.ResponseURL != "https://hooks.slack.com/actions/1" {
+		t.Errorf("unexpected message: %#v", msg)
+	}
+
+	view := transport.Views()[0]
+	if view.TriggerID != "T1" || view.View.Type != "modal" || view.View.CallbackID != "feedback" {
+		t.Errorf("unexpected view: %#v", view)
+	}
+}
@@ -0,0 +1,136 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gobridge/gopher/health"
+	"github.com/nlopes/slack"
+)
+
+// ErrShutdown is returned by Shutdown when called more than once.
+var ErrShutdown = errors.New("bot is shut down")
+
+// SetWorkers configures the pool of workers handling events: the number of
+// workers and how many events may be queued for them. Events for the same
+// channel are handled in order by the same worker, events arriving when the
+// queue is full are dropped and logged.
+//
+// A worker handles the events of several channels, so a slow handler delays
+// the events of every channel sharing its worker, not only its own. More
+// workers make it less likely for busy channels to share one.
+//
+// It must be called before Init.
+func (b *Bot) SetWorkers(workers, queueSize int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.pool.close()
+	b.pool = newPool(workers, queueSize)
+}
+
+// SetEventsCheck reports the connection receiving events into c: events
+// arriving are successes, and disconnections failures. It's meant for RTM
+// connections, which receive a latency report every 30 seconds.
+//
+// It must be called before Init.
+func (b *Bot) SetEventsCheck(c *health.Check) {
+	b.events = c
+}
+
+// reportEvent reports the connection state from an incoming event, see
+// SetEventsCheck.
+func (b *Bot) reportEvent(e slack.RTMEvent) {
+	switch event := e.Data.(type) {
+	case *slack.ConnectingEvent:
+		// Neither connected nor disconnected yet.
+	case *slack.DisconnectedEvent:
+		b.events.Report(fmt.Errorf("disconnected: %v", event.Cause))
+	case *slack.ConnectionErrorEvent:
+		b.events.Report(event)
+	case *slack.InvalidAuthEvent:
+		b.events.Report(errors.New("invalid auth"))
+	default:
+		b.events.Report(nil)
+	}
+}
+
+// CheckAuth checks that the bot can reach the chat backend and is still
+// authorized, such as when the Slack token wasn't revoked.
+func (b *Bot) CheckAuth(ctx context.Context) error {
+	_, err := b.transport.AuthTest(ctx)
+	return err
+}
+
+// CheckWorkers returns an error when events are waiting for a worker but none
+// was handled since the previous call, because the workers are stuck.
+func (b *Bot) CheckWorkers() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := b.pool.stats()
+	handled := b.lastHandled
+	b.lastHandled = s.Handled
+	if s.Queued > 0 && s.Handled == handled {
+		return fmt.Errorf("%d events queued, none handled since last check", s.Queued)
+	}
+	return nil
+}
+
+// Stats returns statistics about the events handled by the Bot.
+func (b *Bot) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.pool.stats()
+}
+
+// dispatch queues f, handling an event of type typ, on the worker for key,
+// usually the channel of the event. f isn't run when the queue is full or the
+// bot is shutting down.
+func (b *Bot) dispatch(typ, key string, f func()) {
+	eventsReceived.WithLabelValues(typ).Inc()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closing {
+		b.logf("shutting down, dropping %s event for %s\n", typ, key)
+		return
+	}
+	task := func() {
+		defer func(start time.Time) { eventDuration.WithLabelValues(typ).Observe(since(start)) }(time.Now())
+		f()
+	}
+	if !b.pool.submit(key, task) {
+		b.logf("event queue full, dropping %s event for %s\n", typ, key)
+	}
+}
+
+// Shutdown stops handling new events and waits for queued and in-flight
+// events to be handled. Events must be stopped first by cancelling the
+// context passed to Init, the events already received are then handled too.
+//
+// When ctx is done before the events are handled, the contexts passed to the
+// handlers are cancelled and Shutdown returns ctx.Err().
+func (b *Bot) Shutdown(ctx context.Context) error {
+	if b.received != nil {
+		select {
+		case <-b.received:
+		case <-ctx.Done():
+		}
+	}
+
+	b.mu.Lock()
+	if b.closing {
+		b.mu.Unlock()
+		return ErrShutdown
+	}
+	b.closing = true
+	b.pool.close()
+	b.mu.Unlock()
+
+	defer b.cancel()
+	return b.pool.wait(ctx)
+}
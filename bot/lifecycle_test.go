@@ -0,0 +1,193 @@
+package bot_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gobridge/gopher/bot"
+	"github.com/gobridge/gopher/bot/bottest"
+	"github.com/nlopes/slack"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestShutdown(t *testing.T) {
+	newBot := func(t *testing.T, h bot.HandlerFunc) (*bot.Bot, *bottest.Transport, context.CancelFunc) {
+		transport := bottest.NewTransport("UGOPHER", "gopher")
+		b := bot.New(transport, false, nopLog, h, nil, nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		if err := b.Init(ctx); err != nil {
+			t.Fatalf("init bot: %v", err)
+		}
+		return b, transport, cancel
+	}
+
+	t.Run("waits for handlers", func(t *testing.T) {
+		started := make(chan struct{})
+		b, transport, cancel := newBot(t, func(ctx context.Context, m bot.Message, r bot.Responder) {
+			close(started)
+			time.Sleep(50 * time.Millisecond)
+			r.Respond(ctx, "done")
+		})
+		transport.SendMessage("C1", "U1", "hello")
+		<-started
+		cancel()
+
+		if err := b.Shutdown(context.Background()); err != nil {
+			t.Fatalf("expected: nil\nactual: %v", err)
+		}
+		if msgs := transport.Messages(); len(msgs) != 1 {
+			t.Errorf("expected 1 message, got %d", len(msgs))
+		}
+	})
+
+	t.Run("cancels handlers after timeout", func(t *testing.T) {
+		started := make(chan struct{})
+		cancelled := make(chan struct{})
+		b, transport, cancel := newBot(t, func(ctx context.Context, m bot.Message, r bot.Responder) {
+			close(started)
+			<-ctx.Done()
+			close(cancelled)
+		})
+		transport.SendMessage("C1", "U1", "hello")
+		<-started
+		cancel()
+
+		ctx, cancelShutdown := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancelShutdown()
+		if err := b.Shutdown(ctx); err != context.DeadlineExceeded {
+			t.Fatalf("expected: %v\nactual: %v", context.DeadlineExceeded, err)
+		}
+
+		select {
+		case <-cancelled:
+		case <-time.After(time.Second):
+			t.Errorf("expected handler context to be cancelled")
+		}
+	})
+
+	t.Run("handles events received before shutdown", func(t *testing.T) {
+		transport := bottest.NewTransport("UGOPHER", "gopher")
+		b := bot.New(transport, false, nopLog, bot.HandlerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) {
+			r.Respond(ctx, "done")
+		}), nil, nil)
+		transport.SendMessage("C1", "U1", "hello")
+		transport.SendMessage("C2", "U1", "hello")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := b.Init(ctx); err != nil {
+			t.Fatalf("init bot: %v", err)
+		}
+
+		if err := b.Shutdown(context.Background()); err != nil {
+			t.Fatalf("expected: nil\nactual: %v", err)
+		}
+		if msgs := transport.Messages(); len(msgs) != 2 {
+			t.Errorf("expected 2 messages, got %d", len(msgs))
+		}
+	})
+}
+
+func TestDroppedEvents(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	h := bot.HandlerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) {
+		started <- struct{}{}
+		<-release
+	})
+
+	var mu sync.Mutex
+	var logs []string
+	logf := func(format string, args ...interface{}) {
+		mu.Lock()
+		logs = append(logs, fmt.Sprintf(format, args...))
+		mu.Unlock()
+	}
+
+	transport := bottest.NewTransport("UGOPHER", "gopher")
+	b := bot.New(transport, false, logf, h, nil, nil)
+	b.SetWorkers(1, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := b.Init(ctx); err != nil {
+		t.Fatalf("init bot: %v", err)
+	}
+
+	transport.SendMessage("C1", "U1", "busy")
+	<-started
+	transport.SendMessage("C1", "U1", "queued")
+	transport.SendMessage("C2", "U1", "dropped")
+
+	expected := "event queue full, dropping message event for C2\n"
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		found := false
+		for _, l := range logs {
+			found = found || l == expected
+		}
+		mu.Unlock()
+		if found {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected log: %q\nactual: %q", expected, logs)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if s := b.Stats(); s.Dropped != 1 {
+		t.Errorf("expected: 1 dropped event\nactual: %d", s.Dropped)
+	}
+	close(release)
+}
+
+func TestRegisterStats(t *testing.T) {
+	r := prometheus.NewPedanticRegistry()
+	b := bot.New(bottest.NewTransport("UGOPHER", "gopher"), false, nopLog, nil, nil, nil)
+	if err := bot.RegisterStats(r, b); err != nil {
+		t.Fatalf("expected: nil\nactual: %v", err)
+	}
+
+	expected := `# HELP gopher_events_dropped_total Events dropped because the queue was full.
+# TYPE gopher_events_dropped_total counter
+gopher_events_dropped_total 0
+`
+	if err := testutil.GatherAndCompare(r, strings.NewReader(expected), "gopher_events_dropped_total"); err != nil {
+		t.Error(err)
+	}
+
+	other := bot.New(bottest.NewTransport("UGOPHER", "gopher"), false, nopLog, nil, nil, nil)
+	if err := bot.RegisterStats(r, other); err == nil {
+		t.Errorf("expected registering another bot to fail")
+	}
+	if n, err := testutil.GatherAndCount(r); err != nil || n != 3 {
+		t.Errorf("expected: 3 metrics\nactual: %d, %v", n, err)
+	}
+}
+
+func TestMissingHandlers(t *testing.T) {
+	transport := bottest.NewTransport("UGOPHER", "gopher")
+	b := bot.New(transport, false, nopLog, nil, nil, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := b.Init(ctx); err != nil {
+		t.Fatalf("init bot: %v", err)
+	}
+
+	// Without join and reaction handlers, the events are ignored.
+	transport.SendTeamJoin(slack.User{ID: "U2", Name: "newgopher"})
+	transport.SendReaction("C1", "1.0", "U1", "x")
+	cancel()
+
+	if err := b.Shutdown(context.Background()); err != nil {
+		t.Fatalf("expected: nil\nactual: %v", err)
+	}
+	if msgs := transport.Messages(); len(msgs) != 0 {
+		t.Errorf("expected no messages, got %#v", msgs)
+	}
+}
@@ -0,0 +1,66 @@
+package bot
+
+import (
+	"time"
+
+	"github.com/gobridge/gopher/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	eventsReceived = metrics.NewCounterVec("gopher_events_received_total",
+		"Events received from Slack, by type.", "type")
+	eventDuration = metrics.NewHistogramVec("gopher_event_duration_seconds",
+		"Time spent handling events, by type, once a worker picked them.", metrics.DefaultBuckets, "type")
+	handlerInvocations = metrics.NewCounterVec("gopher_handler_invocations_total",
+		"Messages passed to handlers, by handler and whether the handler consumed them.", "handler", "consumed")
+	handlerDuration = metrics.NewHistogramVec("gopher_handler_duration_seconds",
+		"Time spent in handlers, by handler.", metrics.DefaultBuckets, "handler")
+	slackCalls = metrics.NewCounterVec("gopher_slack_api_calls_total",
+		"Slack API calls, by method.", "method")
+	slackErrors = metrics.NewCounterVec("gopher_slack_api_errors_total",
+		"Slack API calls which failed, by method.", "method")
+)
+
+// RegisterStats exposes the Stats of b as metrics in r, so a queue that
+// fills up or drops events can be alerted on. It fails when the stats of
+// another Bot are already registered in r.
+func RegisterStats(r prometheus.Registerer, b *Bot) error {
+	collectors := []prometheus.Collector{
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "gopher_events_queued",
+			Help: "Events waiting for a worker.",
+		}, func() float64 { return float64(b.Stats().Queued) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "gopher_events_handled_total",
+			Help: "Events handled by workers.",
+		}, func() float64 { return float64(b.Stats().Handled) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "gopher_events_dropped_total",
+			Help: "Events dropped because the queue was full.",
+		}, func() float64 { return float64(b.Stats().Dropped) }),
+	}
+	for i, c := range collectors {
+		if err := r.Register(c); err != nil {
+			for _, registered := range collectors[:i] {
+				r.Unregister(registered)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// slackCall records a call to the Slack API method which returned err.
+func slackCall(method string, err error) error {
+	slackCalls.WithLabelValues(method).Inc()
+	if err != nil {
+		slackErrors.WithLabelValues(method).Inc()
+	}
+	return err
+}
+
+// since returns the seconds elapsed since start, for histograms.
+func since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
@@ -0,0 +1,111 @@
+package bot
+
+import (
+	"context"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Middleware wraps a Handler to add behaviour around it, such as filtering
+// messages or recovering from panics.
+//
+// The middlewares of this package return a Consumer which consumes the
+// messages the wrapped handler consumed, see Consume.
+type Middleware func(Handler) Handler
+
+// Chain wraps h with mws. The first middleware is the outermost, so it sees
+// messages first.
+func Chain(h Handler, mws ...Middleware) Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// Recover recovers from panics in the handler and reports them with the
+// message and stack trace to report.
+func Recover(report Logger) Middleware {
+	return func(h Handler) Handler {
+		return ConsumerFunc(func(ctx context.Context, m Message, r Responder) (consumed bool) {
+			defer func() {
+				if v := recover(); v != nil {
+					report("panic handling message %q in %s: %v\n%s", m.Event.Text, m.Event.Channel, v, debug.Stack())
+				}
+			}()
+			return Consume(ctx, h, m, r)
+		})
+	}
+}
+
+// Timeout cancels the context passed to the handler after d. The handler
+// must return once the context is done, it isn't abandoned.
+func Timeout(d time.Duration) Middleware {
+	return func(h Handler) Handler {
+		return ConsumerFunc(func(ctx context.Context, m Message, r Responder) bool {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return Consume(ctx, h, m, r)
+		})
+	}
+}
+
+// Trace records a span named name for each message handled by the handler.
+// The span records whether the handler consumed the message.
+func Trace(name string) Middleware {
+	return func(h Handler) Handler {
+		return ConsumerFunc(func(ctx context.Context, m Message, r Responder) bool {
+			ctx, span := StartSpan(ctx, name)
+			defer span.End()
+
+			consumed := Consume(ctx, h, m, r)
+			span.SetAttributes(attribute.Bool("consumed", consumed))
+			return consumed
+		})
+	}
+}
+
+// Measure records the invocations of the handler, whether it consumed the
+// messages and how long it took as metrics labelled with name.
+func Measure(name string) Middleware {
+	return func(h Handler) Handler {
+		return ConsumerFunc(func(ctx context.Context, m Message, r Responder) bool {
+			start := time.Now()
+			consumed := Consume(ctx, h, m, r)
+			handlerDuration.WithLabelValues(name).Observe(since(start))
+			handlerInvocations.WithLabelValues(name, strconv.FormatBool(consumed)).Inc()
+			return consumed
+		})
+	}
+}
+
+// AllowChannels only passes messages in the channels with IDs to the
+// handler.
+func AllowChannels(ids ...string) Middleware {
+	allowed := channelSet(ids)
+	return func(h Handler) Handler {
+		return ConsumerFunc(func(ctx context.Context, m Message, r Responder) bool {
+			return allowed[m.Event.Channel] && Consume(ctx, h, m, r)
+		})
+	}
+}
+
+// DenyChannels doesn't pass messages in the channels with IDs to the handler.
+func DenyChannels(ids ...string) Middleware {
+	denied := channelSet(ids)
+	return func(h Handler) Handler {
+		return ConsumerFunc(func(ctx context.Context, m Message, r Responder) bool {
+			return !denied[m.Event.Channel] && Consume(ctx, h, m, r)
+		})
+	}
+}
+
+func channelSet(ids []string) map[string]bool {
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
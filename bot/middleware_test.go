@@ -0,0 +1,113 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+func TestMiddleware(t *testing.T) {
+	message := func(channel string) Message {
+		return Message{Event: &slack.MessageEvent{Msg: slack.Msg{Channel: channel, Text: "hello"}}}
+	}
+
+	t.Run("chains in order", func(t *testing.T) {
+		var calls []string
+		mw := func(name string) Middleware {
+			return func(h Handler) Handler {
+				return HandlerFunc(func(ctx context.Context, m Message, r Responder) {
+					calls = append(calls, name)
+					h.Handle(ctx, m, r)
+				})
+			}
+		}
+		h := HandlerFunc(func(ctx context.Context, m Message, r Responder) {
+			calls = append(calls, "handler")
+		})
+
+		Chain(h, mw("first"), mw("second")).Handle(context.Background(), message("C1"), nil)
+
+		expected := []string{"first", "second", "handler"}
+		if !reflect.DeepEqual(expected, calls) {
+			t.Errorf("expected: %v\nactual: %v", expected, calls)
+		}
+	})
+
+	t.Run("recovers from panics", func(t *testing.T) {
+		var report string
+		h := HandlerFunc(func(ctx context.Context, m Message, r Responder) {
+			panic("boom")
+		})
+
+		Recover(func(format string, args ...interface{}) {
+			report = fmt.Sprintf(format, args...)
+		})(h).Handle(context.Background(), message("C1"), nil)
+
+		if !strings.Contains(report, "boom") {
+			t.Errorf("expected panic to be reported, got %q", report)
+		}
+	})
+
+	t.Run("times out", func(t *testing.T) {
+		var err error
+		h := HandlerFunc(func(ctx context.Context, m Message, r Responder) {
+			<-ctx.Done()
+			err = ctx.Err()
+		})
+
+		Timeout(time.Millisecond)(h).Handle(context.Background(), message("C1"), nil)
+
+		if err != context.DeadlineExceeded {
+			t.Errorf("expected: %v\nactual: %v", context.DeadlineExceeded, err)
+		}
+	})
+
+	t.Run("filters channels", func(t *testing.T) {
+		var handled []string
+		h := HandlerFunc(func(ctx context.Context, m Message, r Responder) {
+			handled = append(handled, m.Event.Channel)
+		})
+
+		allow := AllowChannels("C1", "C2")(h)
+		deny := DenyChannels("C2")(allow)
+		for _, c := range []string{"C1", "C2", "C3"} {
+			deny.Handle(context.Background(), message(c), nil)
+		}
+
+		expected := []string{"C1"}
+		if !reflect.DeepEqual(expected, handled) {
+			t.Errorf("expected: %v\nactual: %v", expected, handled)
+		}
+	})
+	t.Run("preserves consumers", func(t *testing.T) {
+		consumer := ConsumerFunc(func(ctx context.Context, m Message, r Responder) bool {
+			return true
+		})
+		nop := func(string, ...interface{}) {}
+
+		mws := map[string]Middleware{
+			"Recover":       Recover(nop),
+			"Timeout":       Timeout(time.Second),
+			"Trace":         Trace("test"),
+			"Measure":       Measure("test"),
+			"AllowChannels": AllowChannels("C1"),
+			"DenyChannels":  DenyChannels("C2"),
+		}
+		for name, mw := range mws {
+			if !Consume(context.Background(), mw(consumer), message("C1"), nil) {
+				t.Errorf("expected %s to consume the message", name)
+			}
+			if Consume(context.Background(), mw(HandlerFunc(func(context.Context, Message, Responder) {})), message("C1"), nil) {
+				t.Errorf("expected %s not to consume the message", name)
+			}
+		}
+		if Consume(context.Background(), AllowChannels("C2")(consumer), message("C1"), nil) {
+			t.Errorf("expected filtered message not to be consumed")
+		}
+	})
+}
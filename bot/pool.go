@@ -0,0 +1,115 @@
+package bot
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+)
+
+// Defaults for the worker pool handling events.
+const (
+	DefaultWorkers   = 16
+	DefaultQueueSize = 1024
+)
+
+// Stats are statistics about the events handled by the Bot.
+type Stats struct {
+	Workers int   `json:"workers"` // number of workers handling events
+	Queued  int   `json:"queued"`  // events waiting for a worker
+	Handled int64 `json:"handled"` // events handled since the Bot was created
+	Dropped int64 `json:"dropped"` // events dropped because the queue was full
+}
+
+// pool runs tasks on a fixed number of workers. Tasks with the same key run
+// in the order they were submitted, on the same worker.
+//
+// Keys are assigned to workers by hash, so unrelated keys share a worker and
+// its queue: a slow task delays every task queued behind it, whatever their
+// key, and fills the queue they are dropped from.
+type pool struct {
+	handled int64 // atomic, first for 64-bit alignment
+	dropped int64 // atomic
+	queues  []chan func()
+	wg      sync.WaitGroup
+}
+
+// newPool starts a pool with workers, each queueing up to queueSize/workers
+// tasks.
+func newPool(workers, queueSize int) *pool {
+	if workers < 1 {
+		workers = 1
+	}
+	size := queueSize / workers
+	if size < 1 {
+		size = 1
+	}
+
+	p := &pool{queues: make([]chan func(), workers)}
+	for i := range p.queues {
+		p.queues[i] = make(chan func(), size)
+		p.wg.Add(1)
+		go p.work(p.queues[i])
+	}
+	return p
+}
+
+func (p *pool) work(queue <-chan func()) {
+	defer p.wg.Done()
+	for f := range queue {
+		f()
+		atomic.AddInt64(&p.handled, 1)
+	}
+}
+
+// submit queues f on the worker for key. It returns false when the queue of
+// that worker is full and f was dropped.
+func (p *pool) submit(key string, f func()) bool {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	queue := p.queues[h.Sum32()%uint32(len(p.queues))]
+
+	select {
+	case queue <- f:
+		return true
+	default:
+		atomic.AddInt64(&p.dropped, 1)
+		return false
+	}
+}
+
+// close stops accepting tasks. Workers exit once their queue is drained. No
+// tasks may be submitted after calling close.
+func (p *pool) close() {
+	for _, q := range p.queues {
+		close(q)
+	}
+}
+
+// wait waits for the workers to exit, or until ctx is done.
+func (p *pool) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *pool) stats() Stats {
+	s := Stats{
+		Workers: len(p.queues),
+		Handled: atomic.LoadInt64(&p.handled),
+		Dropped: atomic.LoadInt64(&p.dropped),
+	}
+	for _, q := range p.queues {
+		s.Queued += len(q)
+	}
+	return s
+}
@@ -0,0 +1,73 @@
+package bot
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPool(t *testing.T) {
+	t.Run("runs tasks with the same key in order", func(t *testing.T) {
+		p := newPool(4, 400)
+
+		var mu sync.Mutex
+		var actual []int
+		for i := 0; i < 50; i++ {
+			i := i
+			p.submit("C1", func() {
+				mu.Lock()
+				actual = append(actual, i)
+				mu.Unlock()
+			})
+		}
+		p.close()
+		if err := p.wait(context.Background()); err != nil {
+			t.Fatalf("waiting for pool: %v", err)
+		}
+
+		var expected []int
+		for i := 0; i < 50; i++ {
+			expected = append(expected, i)
+		}
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("expected: %v\nactual: %v", expected, actual)
+		}
+	})
+
+	t.Run("drops tasks when the queue is full", func(t *testing.T) {
+		p := newPool(1, 1)
+
+		block := make(chan struct{})
+		started := make(chan struct{})
+		p.submit("C1", func() {
+			close(started)
+			<-block
+		})
+		<-started
+
+		if !p.submit("C1", func() {}) {
+			t.Fatalf("expected task to be queued")
+		}
+		if p.submit("C1", func() {}) {
+			t.Errorf("expected task to be dropped")
+		}
+
+		expected := Stats{Workers: 1, Queued: 1, Dropped: 1}
+		if s := p.stats(); s != expected {
+			t.Errorf("expected: %+v\nactual: %+v", expected, s)
+		}
+
+		close(block)
+		p.close()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := p.wait(ctx); err != nil {
+			t.Fatalf("waiting for pool: %v", err)
+		}
+		if s := p.stats(); s.Handled != 2 {
+			t.Errorf("expected: 2 handled\nactual: %d", s.Handled)
+		}
+	})
+}
@@ -0,0 +1,213 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/nlopes/slack"
+)
+
+// ErrNoReply is returned when updating or deleting a nil Reply, such as the
+// result of a failed or ephemeral response.
+var ErrNoReply = errors.New("no reply to edit")
+
+// A Reply references a message sent by a Responder, so it can be edited
+// later. Long running handlers can respond with a placeholder and update it
+// once they're done.
+//
+// A nil *Reply is valid, updating or deleting it returns ErrNoReply.
+type Reply struct {
+	bot         *Bot
+	to          string // channel or user the reply was sent to
+	channel     string
+	timestamp   string
+	responseURL string // replies to slash commands are edited through it
+	author      string // user who sent the message the reply responds to
+}
+
+// Channel returns the channel the reply was posted in.
+func (r *Reply) Channel() string {
+	if r == nil {
+		return ""
+	}
+	return r.channel
+}
+
+// Timestamp returns the timestamp of the reply, which identifies it within
+// the channel.
+func (r *Reply) Timestamp() string {
+	if r == nil {
+		return ""
+	}
+	return r.timestamp
+}
+
+// Update replaces the text and blocks of the reply.
+func (r *Reply) Update(ctx context.Context, msg string, blocks ...slack.Block) error {
+	if r == nil {
+		return ErrNoReply
+	}
+
+	if r.responseURL != "" {
+		return r.bot.transport.PostResponse(ctx, r.responseURL, slack.Msg{
+			Text:            msg,
+			Blocks:          slack.Blocks{BlockSet: blocks},
+			ReplaceOriginal: true,
+		})
+	}
+
+	return r.bot.transport.UpdateMessage(ctx, r.channel, r.timestamp,
+		slack.MsgOptionText(msg, false),
+		slack.MsgOptionBlocks(blocks...),
+	)
+}
+
+// Delete deletes the reply.
+func (r *Reply) Delete(ctx context.Context) error {
+	if r == nil {
+		return ErrNoReply
+	}
+	r.bot.replies.remove(r)
+
+	if r.responseURL != "" {
+		return r.bot.transport.PostResponse(ctx, r.responseURL, slack.Msg{DeleteOriginal: true})
+	}
+
+	return r.bot.transport.DeleteMessage(ctx, r.channel, r.timestamp)
+}
+
+// replyLogSize is the number of messages replies are remembered for.
+const replyLogSize = 1000
+
+// messageRef identifies a message.
+type messageRef struct {
+	channel   string
+	timestamp string
+}
+
+// replyLog maps messages to the replies the bot sent in response. Only the
+// most recent replyLogSize messages are remembered, and only in memory: after
+// a restart, editing or deleting an earlier message leaves the replies to it
+// unchanged, and they can't be dismissed with a reaction.
+type replyLog struct {
+	mu      sync.Mutex
+	replies map[messageRef][]*Reply
+	refs    []messageRef // oldest first
+}
+
+// add records reply as a response to the message ref.
+func (l *replyLog) add(ref messageRef, reply *Reply) {
+	if ref.timestamp == "" || reply == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.replies == nil {
+		l.replies = make(map[messageRef][]*Reply)
+	}
+	if _, ok := l.replies[ref]; !ok {
+		if len(l.refs) >= replyLogSize {
+			delete(l.replies, l.refs[0])
+			l.refs = l.refs[1:]
+		}
+		l.refs = append(l.refs, ref)
+	}
+	l.replies[ref] = append(l.replies[ref], reply)
+}
+
+// take removes and returns the replies to the message ref.
+func (l *replyLog) take(ref messageRef) []*Reply {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	replies, ok := l.replies[ref]
+	if !ok {
+		return nil
+	}
+	l.forget(ref)
+	return replies
+}
+
+// forget removes the message ref, l.mu must be held.
+func (l *replyLog) forget(ref messageRef) {
+	delete(l.replies, ref)
+	for i, r := range l.refs {
+		if r == ref {
+			l.refs = append(l.refs[:i], l.refs[i+1:]...)
+			break
+		}
+	}
+}
+
+// find returns the reply with timestamp in channel, or nil.
+func (l *replyLog) find(channel, timestamp string) *Reply {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, replies := range l.replies {
+		for _, r := range replies {
+			if r.channel == channel && r.timestamp == timestamp {
+				return r
+			}
+		}
+	}
+	return nil
+}
+
+// remove forgets reply, so it isn't edited or deleted again.
+func (l *replyLog) remove(reply *Reply) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for ref, replies := range l.replies {
+		for i, r := range replies {
+			if r == reply {
+				if len(replies) == 1 {
+					l.forget(ref)
+				} else {
+					l.replies[ref] = append(replies[:i], replies[i+1:]...)
+				}
+				return
+			}
+		}
+	}
+}
+
+// pendingReplies are replies to the previous version of an edited message,
+// waiting to be replaced by responses to the new version.
+type pendingReplies struct {
+	mu      sync.Mutex
+	replies []*Reply
+}
+
+// next removes and returns the first pending reply sent to the channel or
+// user to, or nil. It is safe to call on a nil *pendingReplies.
+func (p *pendingReplies) next(to string) *Reply {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, r := range p.replies {
+		if r.to == to {
+			p.replies = append(p.replies[:i], p.replies[i+1:]...)
+			return r
+		}
+	}
+	return nil
+}
+
+// rest removes and returns the replies which weren't replaced.
+func (p *pendingReplies) rest() []*Reply {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	rest := p.replies
+	p.replies = nil
+	return rest
+}
@@ -0,0 +1,239 @@
+package bot_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gobridge/gopher/bot"
+	"github.com/gobridge/gopher/bot/bottest"
+)
+
+func TestReply(t *testing.T) {
+	newBot := func(t *testing.T, h bot.HandlerFunc) *bottest.Transport {
+		transport := bottest.NewTransport("UGOPHER", "gopher")
+		b := bot.New(transport, false, nopLog, h, nil, nil)
+		if err := b.Init(context.Background()); err != nil {
+			t.Fatalf("init bot: %v", err)
+		}
+		return transport
+	}
+
+	t.Run("updates reply", func(t *testing.T) {
+		transport := newBot(t, func(ctx context.Context, m bot.Message, r bot.Responder) {
+			reply := r.Respond(ctx, "working…")
+			if err := reply.Update(ctx, "done"); err != nil {
+				t.Errorf("updating reply: %v", err)
+			}
+		})
+		transport.SendMessage("C1", "U1", "hello")
+
+		ok := transport.Wait(time.Second, func(tr *bottest.Transport) bool {
+			msgs := tr.Messages()
+			return len(msgs) == 1 && msgs[0].Edited
+		})
+		if !ok {
+			t.Fatalf("expected 1 edited message, got %#v", transport.Messages())
+		}
+		if msg := transport.Messages()[0]; msg.Text != "done" || msg.Channel != "C1" {
+			t.Errorf("expected: %q in C1\nactual: %q in %s", "done", msg.Text, msg.Channel)
+		}
+	})
+
+	t.Run("deletes reply", func(t *testing.T) {
+		transport := newBot(t, func(ctx context.Context, m bot.Message, r bot.Responder) {
+			reply := r.Respond(ctx, "working…")
+			if err := reply.Delete(ctx); err != nil {
+				t.Errorf("deleting reply: %v", err)
+			}
+		})
+		transport.SendMessage("C1", "U1", "hello")
+
+		ok := transport.Wait(time.Second, func(tr *bottest.Transport) bool {
+			msgs := tr.Messages()
+			return len(msgs) == 1 && msgs[0].Deleted
+		})
+		if !ok {
+			t.Fatalf("expected 1 deleted message, got %#v", transport.Messages())
+		}
+	})
+
+	echo := func(ctx context.Context, m bot.Message, r bot.Responder) {
+		if m.TrimmedText != "nolink" {
+			r.Respond(ctx, "echo: "+m.TrimmedText)
+		}
+	}
+
+	t.Run("updates reply to edited message", func(t *testing.T) {
+		transport := newBot(t, echo)
+		ts := transport.SendMessage("C1", "U1", "helo")
+		transport.Wait(time.Second, func(tr *bottest.Transport) bool {
+			return len(tr.Messages()) == 1
+		})
+		transport.SendEdit("C1", "U1", ts, "hello")
+
+		ok := transport.Wait(time.Second, func(tr *bottest.Transport) bool {
+			msgs := tr.Messages()
+			return len(msgs) == 1 && msgs[0].Edited
+		})
+		if !ok {
+			t.Fatalf("expected 1 edited message, got %#v", transport.Messages())
+		}
+		if msg := transport.Messages()[0]; msg.Text != "echo: hello" {
+			t.Errorf("expected: %q\nactual: %q", "echo: hello", msg.Text)
+		}
+	})
+
+	t.Run("deletes reply to edited message", func(t *testing.T) {
+		transport := newBot(t, echo)
+		ts := transport.SendMessage("C1", "U1", "hello")
+		transport.Wait(time.Second, func(tr *bottest.Transport) bool {
+			return len(tr.Messages()) == 1
+		})
+		transport.SendEdit("C1", "U1", ts, "nolink")
+
+		ok := transport.Wait(time.Second, func(tr *bottest.Transport) bool {
+			msgs := tr.Messages()
+			return len(msgs) == 1 && msgs[0].Deleted
+		})
+		if !ok {
+			t.Fatalf("expected 1 deleted message, got %#v", transport.Messages())
+		}
+	})
+
+	t.Run("ignores edited message without reply", func(t *testing.T) {
+		transport := newBot(t, echo)
+		ts := transport.SendMessage("C1", "U1", "nolink")
+		transport.SendEdit("C1", "U1", ts, "hello")
+
+		if transport.Wait(100*time.Millisecond, func(tr *bottest.Transport) bool {
+			return len(tr.Messages()) > 0
+		}) {
+			t.Errorf("expected no messages, got %#v", transport.Messages())
+		}
+	})
+
+	t.Run("only updates replies to edited message", func(t *testing.T) {
+		transport := newBot(t, func(ctx context.Context, m bot.Message, r bot.Responder) {
+			r.React(ctx, "wave")
+			r.RespondEphemeral(ctx, "hint: "+m.TrimmedText)
+			r.Respond(ctx, "echo: "+m.TrimmedText)
+			if m.TrimmedText == "hello twice" {
+				r.Respond(ctx, "echo again")
+			}
+		})
+		ts := transport.SendMessage("C1", "U1", "hello")
+		transport.Wait(time.Second, func(tr *bottest.Transport) bool {
+			return len(tr.Messages()) == 2
+		})
+		transport.SendEdit("C1", "U1", ts, "hello twice")
+
+		ok := transport.Wait(time.Second, func(tr *bottest.Transport) bool {
+			msgs := tr.Messages()
+			return len(msgs) == 2 && msgs[1].Edited
+		})
+		if !ok {
+			t.Fatalf("expected the reply to be edited, got %#v", transport.Messages())
+		}
+		if transport.Wait(100*time.Millisecond, func(tr *bottest.Transport) bool {
+			return len(tr.Messages()) > 2 || len(tr.Reactions()) > 1
+		}) {
+			t.Errorf("expected no new messages or reactions, got %#v and %#v", transport.Messages(), transport.Reactions())
+		}
+		if msg := transport.Messages()[1]; msg.Text != "echo: hello twice" {
+			t.Errorf("expected: %q\nactual: %q", "echo: hello twice", msg.Text)
+		}
+	})
+
+	t.Run("deletes reply to deleted message", func(t *testing.T) {
+		transport := newBot(t, echo)
+		ts := transport.SendMessage("C1", "U1", "hello")
+		transport.Wait(time.Second, func(tr *bottest.Transport) bool {
+			return len(tr.Messages()) == 1
+		})
+		transport.SendDelete("C1", ts)
+
+		ok := transport.Wait(time.Second, func(tr *bottest.Transport) bool {
+			msgs := tr.Messages()
+			return len(msgs) == 1 && msgs[0].Deleted
+		})
+		if !ok {
+			t.Fatalf("expected 1 deleted message, got %#v", transport.Messages())
+		}
+	})
+
+	// deleting responds to "two" with two replies and deletes the first one,
+	// and deletes its reply to "drop".
+	deleting := func(ctx context.Context, m bot.Message, r bot.Responder) {
+		switch m.TrimmedText {
+		case "two":
+			first := r.Respond(ctx, "first")
+			r.Respond(ctx, "second")
+			first.Delete(ctx)
+		case "drop":
+			r.Respond(ctx, "dropped").Delete(ctx)
+		default:
+			r.Respond(ctx, "echo: "+m.TrimmedText)
+		}
+	}
+
+	t.Run("keeps other replies when one is deleted", func(t *testing.T) {
+		transport := newBot(t, deleting)
+		ts := transport.SendMessage("C1", "U1", "two")
+		transport.Wait(time.Second, func(tr *bottest.Transport) bool {
+			msgs := tr.Messages()
+			return len(msgs) == 2 && msgs[0].Deleted
+		})
+		transport.SendEdit("C1", "U1", ts, "edited")
+
+		ok := transport.Wait(time.Second, func(tr *bottest.Transport) bool {
+			msgs := tr.Messages()
+			return len(msgs) == 2 && msgs[1].Edited
+		})
+		if !ok {
+			t.Fatalf("expected the second reply to be edited, got %#v", transport.Messages())
+		}
+		if msg := transport.Messages()[1]; msg.Deleted || msg.Text != "echo: edited" {
+			t.Errorf("expected: %q\nactual: %#v", "echo: edited", msg)
+		}
+	})
+
+	t.Run("forgets message once its replies are deleted", func(t *testing.T) {
+		transport := newBot(t, deleting)
+		ts := transport.SendMessage("C1", "U1", "hello")
+
+		// Messages without replies left don't count towards the 1000
+		// messages replies are remembered for.
+		for i := 1; i <= 1000; i++ {
+			transport.SendMessage("C1", "U1", "drop")
+			if i%50 == 0 {
+				// Let the queue of the channel drain, rather than fill it.
+				if !transport.Wait(5*time.Second, func(tr *bottest.Transport) bool {
+					return len(tr.Messages()) == 1+i
+				}) {
+					t.Fatalf("expected %d messages, got %d", 1+i, len(transport.Messages()))
+				}
+			}
+		}
+		transport.SendMessage("C1", "U1", "hello again")
+		transport.SendEdit("C1", "U1", ts, "edited")
+
+		ok := transport.Wait(5*time.Second, func(tr *bottest.Transport) bool {
+			msgs := tr.Messages()
+			return len(msgs) > 0 && msgs[0].Edited
+		})
+		if !ok {
+			t.Fatalf("expected the reply to the first message to be edited, got %#v", transport.Messages())
+		}
+	})
+
+	t.Run("nil reply", func(t *testing.T) {
+		var reply *bot.Reply
+		if err := reply.Update(context.Background(), "done"); err != bot.ErrNoReply {
+			t.Errorf("expected: %v\nactual: %v", bot.ErrNoReply, err)
+		}
+		if err := reply.Delete(context.Background()); err != bot.ErrNoReply {
+			t.Errorf("expected: %v\nactual: %v", bot.ErrNoReply, err)
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/nlopes/slack"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// SlashCommands returns an http.Handler for Slack slash command requests,
+// such as `/gopher newbie resources`. It must be registered as the Request URL
+// of the slash command.
+//
+// The command text is passed to the Handler as a Message directed to the bot.
+// Responses are sent to the command's response_url and are only visible to
+// the user running the command, unless inChannel is true.
+func (b *Bot) SlashCommands(signingSecret string, inChannel bool) http.Handler {
+	responseType := slashResponseType(inChannel)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.NotFound(w, r)
+			return
+		}
+
+		body, err := readVerifiedBody(r, signingSecret)
+		if err != nil {
+			b.logf("rejecting slash command: %v\n", err)
+			http.Error(w, "invalid request signature", http.StatusUnauthorized)
+			return
+		}
+
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		cmd, err := slack.SlashCommandParse(r)
+		if err != nil {
+			b.logf("parsing slash command: %v\n", err)
+			http.Error(w, "invalid payload", http.StatusBadRequest)
+			return
+		}
+
+		// Slack requires an acknowledgement within 3 seconds, responses
+		// are sent to the response_url.
+		w.WriteHeader(http.StatusOK)
+
+		b.dispatch("slash_command", cmd.ChannelID, func() { b.handleSlashCommand(cmd, responseType) })
+	})
+}
+
+// slashResponseType is the response type of slash command responses.
+func slashResponseType(inChannel bool) string {
+	if inChannel {
+		return slack.ResponseTypeInChannel
+	}
+	return slack.ResponseTypeEphemeral
+}
+
+// handleSlashCommand passes the slash command to the Handler.
+func (b *Bot) handleSlashCommand(cmd slack.SlashCommand, responseType string) {
+	ctx, span := StartSpan(b.base, "Bot.HandleSlashCommand", attribute.String("command", cmd.Command))
+	defer span.End()
+
+	if b.devMode {
+		b.logf("got slash command: %s %s\n", cmd.Command, cmd.Text)
+	}
+
+	event := &slack.MessageEvent{
+		Msg: slack.Msg{
+			Type:    "message",
+			Channel: cmd.ChannelID,
+			User:    cmd.UserID,
+			Text:    cmd.Text,
+		},
+	}
+
+	m := Message{
+		Event:         event,
+		TrimmedText:   strings.TrimSpace(strings.ToLower(cmd.Text)),
+		DirectedToBot: true,
+	}
+	r := slashResponder{
+		bot:          b,
+		cmd:          cmd,
+		responseType: responseType,
+	}
+
+	b.currentHandlers().message.Handle(ctx, m, r)
+}
+
+type slashResponder struct {
+	bot          *Bot
+	cmd          slack.SlashCommand
+	responseType string
+}
+
+// respond posts msg to the response_url, the returned Reply edits the
+// command's most recent response.
+func (r slashResponder) respond(ctx context.Context, msg slack.Msg) *Reply {
+	if r.bot.devMode {
+		r.bot.logf("should reply to slash command %s %s with %s\n", r.cmd.Command, r.cmd.Text, msg.Text)
+	}
+	err := r.bot.transport.PostResponse(ctx, r.cmd.ResponseURL, msg)
+	if err != nil {
+		r.bot.logf("%s\n", err)
+		return nil
+	}
+	return &Reply{bot: r.bot, channel: r.cmd.ChannelID, responseURL: r.cmd.ResponseURL}
+}
+
+func (r slashResponder) Respond(ctx context.Context, msg string) *Reply {
+	return r.respond(ctx, slack.Msg{
+		Text:         msg,
+		ResponseType: r.responseType,
+	})
+}
+
+func (r slashResponder) RespondUnfurled(ctx context.Context, msg string) *Reply {
+	return r.Respond(ctx, msg)
+}
+
+func (r slashResponder) RespondWithAttachment(ctx context.Context, msg, attachment string) *Reply {
+	return r.respond(ctx, slack.Msg{
+		Text:         msg,
+		Attachments:  []slack.Attachment{{Text: attachment}},
+		ResponseType: r.responseType,
+	})
+}
+
+func (r slashResponder) RespondPrivate(ctx context.Context, msg string) *Reply {
+	return r.respond(ctx, slack.Msg{
+		Text:         msg,
+		ResponseType: slack.ResponseTypeEphemeral,
+	})
+}
+
+func (r slashResponder) RespondPrivateWithAttachment(ctx context.Context, msg, attachment string) *Reply {
+	return r.respond(ctx, slack.Msg{
+		Text:         msg,
+		Attachments:  []slack.Attachment{{Text: attachment}},
+		ResponseType: slack.ResponseTypeEphemeral,
+	})
+}
+
+func (r slashResponder) RespondWithBlocks(ctx context.Context, msg string, blocks ...slack.Block) *Reply {
+	return r.respond(ctx, slack.Msg{
+		Text:         msg,
+		Blocks:       slack.Blocks{BlockSet: blocks},
+		ResponseType: r.responseType,
+	})
+}
+
+func (r slashResponder) RespondPrivateWithBlocks(ctx context.Context, msg string, blocks ...slack.Block) *Reply {
+	return r.respond(ctx, slack.Msg{
+		Text:         msg,
+		Blocks:       slack.Blocks{BlockSet: blocks},
+		ResponseType: slack.ResponseTypeEphemeral,
+	})
+}
+
+func (r slashResponder) RespondEphemeral(ctx context.Context, msg string, blocks ...slack.Block) *Reply {
+	return r.RespondPrivateWithBlocks(ctx, msg, blocks...)
+}
+
+// React is a no-op, there is no message to react to.
+func (r slashResponder) React(ctx context.Context, reaction string) {
+	if r.bot.devMode {
+		r.bot.logf("ignoring reaction %s to slash command %s %s\n", reaction, r.cmd.Command, r.cmd.Text)
+	}
+}
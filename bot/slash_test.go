@@ -0,0 +1,68 @@
+package bot_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gobridge/gopher/bot"
+	"github.com/gobridge/gopher/bot/bottest"
+	"github.com/nlopes/slack"
+)
+
+func TestSlashCommands(t *testing.T) {
+	h := bot.HandlerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) {
+		if m.DirectedToBot && m.TrimmedText == "coin flip" {
+			r.Respond(ctx, "heads")
+		}
+	})
+
+	transport := bottest.NewTransport("UGOPHER", "gopher")
+	b := bot.New(transport, false, nopLog, h, nil, nil)
+
+	form := url.Values{
+		"command":      {"/gopher"},
+		"text":         {" Coin Flip "},
+		"channel_id":   {"C1"},
+		"user_id":      {"U1"},
+		"response_url": {"https://hooks.slack.com/commands/1"},
+	}
+
+	t.Run("rejects invalid signature", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		b.SlashCommands(testSecret, false).ServeHTTP(w, bottest.SignedRequest("wrong", "/slack/commands", form.Encode()))
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected: %d\nactual: %d", http.StatusUnauthorized, w.Code)
+		}
+	})
+
+	t.Run("responds to response_url", func(t *testing.T) {
+		req := bottest.SignedRequest(testSecret, "/slack/commands", form.Encode())
+		w := httptest.NewRecorder()
+		b.SlashCommands(testSecret, false).ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected: %d\nactual: %d", http.StatusOK, w.Code)
+		}
+
+		ok := transport.Wait(time.Second, func(tr *bottest.Transport) bool {
+			return len(tr.Messages()) == 1
+		})
+		if !ok {
+			t.Fatalf("expected 1 message, got %d", len(transport.Messages()))
+		}
+
+		expected := bottest.Message{
+			Text:         "heads",
+			ResponseURL:  "https://hooks.slack.com/commands/1",
+			ResponseType: slack.ResponseTypeEphemeral,
+		}
+		if msg := transport.Messages()[0]; msg.Text != expected.Text || msg.ResponseURL != expected.ResponseURL || msg.ResponseType != expected.ResponseType {
+			t.Errorf("expected: %#v\nactual: %#v", expected, msg)
+		}
+	})
+}
@@ -0,0 +1,273 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gobridge/gopher/health"
+	"github.com/gorilla/websocket"
+	"github.com/nlopes/slack"
+)
+
+const (
+	connectionsOpenURL = "https://slack.com/api/apps.connections.open"
+
+	// socketModeReadTimeout is how long a connection may be silent before
+	// it is considered dead. Slack pings connections more frequently.
+	socketModeReadTimeout = 2 * time.Minute
+
+	maxSocketModeBackoff = time.Minute
+)
+
+// SocketMode is an EventSource which receives events over a Slack Socket Mode
+// connection. It doesn't require a public HTTP endpoint.
+//
+// Slash commands and interactions are delivered as events too, with a
+// *slack.SlashCommand or *Interaction as data, see Bot.SetRequestHandlers.
+//
+// The connection is reestablished when Slack asks the client to disconnect
+// or the connection is lost.
+//
+// https://api.slack.com/apis/connections/socket
+type SocketMode struct {
+	appToken string
+	openURL  string
+	http     *http.Client
+	logf     Logger
+	dialer   *websocket.Dialer
+	events   chan slack.RTMEvent
+	seen     seenEvents
+	check    *health.Check
+}
+
+// NewSocketMode creates a SocketMode connecting with the app-level token
+// appToken (xapp-...) which must have the connections:write scope.
+func NewSocketMode(appToken string, c *http.Client, log Logger) *SocketMode {
+	return &SocketMode{
+		appToken: appToken,
+		openURL:  connectionsOpenURL,
+		http:     c,
+		logf:     log,
+		dialer:   websocket.DefaultDialer,
+		events:   make(chan slack.RTMEvent, 50),
+	}
+}
+
+// SetCheck reports the connection into c: greetings, pings and envelopes
+// from Slack are successes, and failing to connect or losing the connection
+// failures.
+//
+// It must be called before Events.
+func (s *SocketMode) SetCheck(c *health.Check) {
+	s.check = c
+}
+
+// Events implements EventSource. It starts managing the connection, which is
+// closed when ctx is done.
+func (s *SocketMode) Events(ctx context.Context) <-chan slack.RTMEvent {
+	go s.manageConnection(ctx)
+	return s.events
+}
+
+// manageConnection keeps a connection open until ctx is done.
+func (s *SocketMode) manageConnection(ctx context.Context) {
+	var backoff time.Duration
+	for ctx.Err() == nil {
+		connected, err := s.connect(ctx)
+		if connected {
+			backoff = 0
+		}
+		if err == nil {
+			continue
+		}
+		s.check.Report(err)
+
+		switch {
+		case backoff == 0:
+			backoff = time.Second
+		case backoff < maxSocketModeBackoff:
+			backoff *= 2
+		}
+		s.logf("socket mode connection failed, reconnecting in %s: %v\n", backoff, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+		}
+	}
+}
+
+// socketModeEnvelope is a message received over a Socket Mode connection.
+type socketModeEnvelope struct {
+	EnvelopeID   string          `json:"envelope_id"`
+	Type         string          `json:"type"`
+	Reason       string          `json:"reason"`
+	RetryAttempt int             `json:"retry_attempt"`
+	Payload      json.RawMessage `json:"payload"`
+}
+
+// socketModeAck acknowledges an envelope.
+type socketModeAck struct {
+	EnvelopeID string `json:"envelope_id"`
+}
+
+// connect opens a connection and delivers events until Slack asks to
+// disconnect, in which case the returned error is nil. connected reports
+// whether Slack greeted the connection.
+func (s *SocketMode) connect(ctx context.Context) (connected bool, err error) {
+	url, err := s.openConnection(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	conn, _, err := s.dialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return false, fmt.Errorf("dialing: %v", err)
+	}
+	defer conn.Close()
+
+	// Close the connection when ctx is done to interrupt reading.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+				time.Now().Add(time.Second))
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	conn.SetReadDeadline(time.Now().Add(socketModeReadTimeout))
+	conn.SetPingHandler(func(data string) error {
+		s.check.Report(nil)
+		conn.SetReadDeadline(time.Now().Add(socketModeReadTimeout))
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(10*time.Second))
+	})
+
+	for {
+		var env socketModeEnvelope
+		if err := conn.ReadJSON(&env); err != nil {
+			if ctx.Err() != nil {
+				return connected, nil
+			}
+			return connected, fmt.Errorf("reading: %v", err)
+		}
+		conn.SetReadDeadline(time.Now().Add(socketModeReadTimeout))
+		s.check.Report(nil)
+
+		// Envelopes must be acknowledged within 3 seconds, otherwise Slack
+		// retries the delivery.
+		if env.EnvelopeID != "" {
+			if err := conn.WriteJSON(socketModeAck{EnvelopeID: env.EnvelopeID}); err != nil {
+				return connected, fmt.Errorf("acknowledging envelope: %v", err)
+			}
+		}
+
+		switch env.Type {
+		case "hello":
+			connected = true
+			s.logf("socket mode connected\n")
+
+		case "disconnect":
+			// Sent when the connection is about to be refreshed
+			// ("warning", "refresh_requested") or the app was
+			// disabled ("link_disabled").
+			s.logf("socket mode disconnect requested: %s\n", env.Reason)
+			if env.Reason == "link_disabled" {
+				return connected, fmt.Errorf("socket mode disabled for app")
+			}
+			return connected, nil
+
+		case "events_api":
+			s.handleEventsAPI(ctx, env)
+
+		case "slash_commands":
+			var cmd slack.SlashCommand
+			if err := json.Unmarshal(env.Payload, &cmd); err != nil {
+				s.logf("unmarshaling socket mode slash command: %v\n", err)
+				continue
+			}
+			s.deliver(ctx, slack.RTMEvent{Type: env.Type, Data: &cmd})
+
+		case "interactive":
+			var i Interaction
+			if err := json.Unmarshal(env.Payload, &i); err != nil {
+				s.logf("unmarshaling socket mode interaction: %v\n", err)
+				continue
+			}
+			s.deliver(ctx, slack.RTMEvent{Type: env.Type, Data: &i})
+		}
+	}
+}
+
+func (s *SocketMode) handleEventsAPI(ctx context.Context, env socketModeEnvelope) {
+	var payload eventsAPIPayload
+	if err := json.Unmarshal(env.Payload, &payload); err != nil {
+		s.logf("unmarshaling socket mode payload: %v\n", err)
+		return
+	}
+
+	if !s.seen.add(payload.EventID) {
+		s.logf("dropping retried event %s (attempt %d)\n", payload.EventID, env.RetryAttempt)
+		return
+	}
+
+	event, ok, err := decodeEvent(payload.Event)
+	if err != nil {
+		s.logf("unmarshaling event %s: %v\n", payload.EventID, err)
+		return
+	}
+	if !ok {
+		return
+	}
+	s.deliver(ctx, event)
+}
+
+// deliver sends event to the Bot, unless ctx is done first.
+func (s *SocketMode) deliver(ctx context.Context, event slack.RTMEvent) {
+	select {
+	case s.events <- event:
+	case <-ctx.Done():
+	}
+}
+
+// openConnection calls apps.connections.open and returns the WebSocket URL.
+func (s *SocketMode) openConnection(ctx context.Context) (string, error) {
+	req, err := http.NewRequest("POST", s.openURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+s.appToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("calling apps.connections.open: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("apps.connections.open: non-200 status code: %d", resp.StatusCode)
+	}
+
+	var body struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+		URL   string `json:"url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("unmarshaling apps.connections.open response: %v", err)
+	}
+	if !body.OK {
+		return "", fmt.Errorf("apps.connections.open: %s", body.Error)
+	}
+
+	return body.URL, nil
+}
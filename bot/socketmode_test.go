@@ -0,0 +1,190 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gobridge/gopher/health"
+	"github.com/gorilla/websocket"
+	"github.com/nlopes/slack"
+)
+
+func TestSocketMode(t *testing.T) {
+	acks := make(chan string, 10)
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	mux.HandleFunc("/apps.connections.open", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer xapp-test" {
+			t.Errorf("unexpected authorization: %q", r.Header.Get("Authorization"))
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"ok":  true,
+			"url": "ws" + strings.TrimPrefix(srv.URL, "http") + "/link",
+		})
+	})
+	mux.HandleFunc("/link", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrading: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		envelope := func(id, eventID string) map[string]interface{} {
+			return map[string]interface{}{
+				"envelope_id": id,
+				"type":        "events_api",
+				"payload": map[string]interface{}{
+					"type":     "event_callback",
+					"event_id": eventID,
+					"event":    map[string]string{"type": "message", "channel": "C1", "user": "U1", "text": "hi"},
+				},
+			}
+		}
+
+		conn.WriteJSON(map[string]string{"type": "hello"})
+		conn.WriteJSON(envelope("env1", "Ev1"))
+		conn.WriteJSON(envelope("env2", "Ev1")) // retried delivery
+		conn.WriteJSON(map[string]interface{}{
+			"envelope_id": "env3",
+			"type":        "slash_commands",
+			"payload":     map[string]string{"command": "/gopher", "text": "help", "channel_id": "C1", "user_id": "U1"},
+		})
+		conn.WriteJSON(map[string]interface{}{
+			"envelope_id": "env4",
+			"type":        "interactive",
+			"payload": map[string]interface{}{
+				"type":    "block_actions",
+				"channel": map[string]string{"id": "C1"},
+				"actions": []map[string]string{{"action_id": "dismiss"}},
+			},
+		})
+
+		for i := 0; i < 4; i++ {
+			var ack socketModeAck
+			if err := conn.ReadJSON(&ack); err != nil {
+				return
+			}
+			acks <- ack.EnvelopeID
+		}
+		conn.ReadMessage() // wait for the client to go away
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	checks := health.NewRegistry()
+	s := NewSocketMode("xapp-test", srv.Client(), func(string, ...interface{}) {})
+	s.openURL = srv.URL + "/apps.connections.open"
+	s.SetCheck(checks.Register("slack.socket", time.Minute, true))
+	go s.manageConnection(ctx)
+
+	next := func() slack.RTMEvent {
+		t.Helper()
+		select {
+		case event := <-s.events:
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for event")
+			return slack.RTMEvent{}
+		}
+	}
+
+	if msg, ok := next().Data.(*slack.MessageEvent); !ok || msg.Text != "hi" {
+		t.Errorf("unexpected message: %#v", msg)
+	}
+	if cmd, ok := next().Data.(*slack.SlashCommand); !ok || cmd.Command != "/gopher" || cmd.Text != "help" || cmd.ChannelID != "C1" {
+		t.Errorf("unexpected slash command: %#v", cmd)
+	}
+	if i, ok := next().Data.(*Interaction); !ok || i.Type != "block_actions" || i.Channel.ID != "C1" || len(i.Actions) != 1 {
+		t.Errorf("unexpected interaction: %#v", i)
+	}
+
+	for _, expected := range []string{"env1", "env2", "env3", "env4"} {
+		select {
+		case id := <-acks:
+			if id != expected {
+				t.Errorf("expected: %q\nactual: %q", expected, id)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for ack")
+		}
+	}
+
+	select {
+	case event := <-s.events:
+		t.Errorf("expected retried event to be dropped, got %#v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if !checks.Ready() {
+		t.Errorf("expected the connection to be reported, got %+v", checks.Status())
+	}
+}
+
+func TestSocketModeCheck(t *testing.T) {
+	opened := make(chan struct{}, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": false, "error": "invalid_auth"})
+		opened <- struct{}{}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	checks := health.NewRegistry()
+	s := NewSocketMode("xapp-revoked", srv.Client(), func(string, ...interface{}) {})
+	s.openURL = srv.URL
+	s.SetCheck(checks.Register("slack.socket", time.Minute, true))
+	go s.manageConnection(ctx)
+
+	select {
+	case <-opened:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for apps.connections.open")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for checks.Status().Checks[0].Failures == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the failure to be reported")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if c := checks.Status().Checks[0]; c.Status != health.StatusFailing || c.LastError != "apps.connections.open: invalid_auth" {
+		t.Errorf("expected the failed connection to be reported, got %+v", c)
+	}
+	if checks.Ready() {
+		t.Error("expected not to be ready")
+	}
+}
+
+func TestSeenEvents(t *testing.T) {
+	var s seenEvents
+	s.add("Ev1")
+	s.forget("Ev1")
+	if !s.add("Ev1") {
+		t.Fatalf("expected forgotten event to be added again")
+	}
+
+	// Filling the log must evict Ev1 once, not a stale copy of it.
+	for i := 0; i < seenEventsSize-1; i++ {
+		s.add("Ev" + strconv.Itoa(i+2))
+	}
+	if len(s.ids) != len(s.seen) {
+		t.Errorf("expected: %d ids\nactual: %d", len(s.seen), len(s.ids))
+	}
+	if s.add("Ev1") {
+		t.Errorf("expected Ev1 to still be seen")
+	}
+}
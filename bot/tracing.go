@@ -0,0 +1,29 @@
+package bot
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans of the bot in OpenTelemetry.
+const instrumentationName = "github.com/gobridge/gopher"
+
+// StartSpan starts a span named name with attrs, as a child of the span in
+// ctx if any. Spans are recorded by the global OpenTelemetry TracerProvider,
+// see otel.SetTracerProvider, and are discarded until one is set.
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// SpanError records err on span and marks it as failed, unless err is nil.
+func SpanError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
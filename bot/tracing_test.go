@@ -0,0 +1,48 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestStartSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	defer tp.Shutdown(context.Background())
+
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(previous)
+
+	ctx, parent := StartSpan(context.Background(), "parent")
+	_, child := StartSpan(ctx, "child", attribute.String("command", "help"))
+	SpanError(child, errors.New("boom"))
+	child.End()
+	SpanError(parent, nil)
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected: 2 spans\nactual: %d", len(spans))
+	}
+	c, p := spans[0], spans[1]
+	if c.Name() != "child" || c.Parent().SpanID() != p.SpanContext().SpanID() {
+		t.Errorf("expected child of %s, got %s with parent %s", p.SpanContext().SpanID(), c.Name(), c.Parent().SpanID())
+	}
+	if attrs := c.Attributes(); len(attrs) != 1 || attrs[0] != attribute.String("command", "help") {
+		t.Errorf("expected: command=help\nactual: %v", attrs)
+	}
+	if c.Status().Code != codes.Error || c.Status().Description != "boom" {
+		t.Errorf("expected child to have failed, got %+v", c.Status())
+	}
+	if p.Status().Code != codes.Unset {
+		t.Errorf("expected parent not to have failed, got %+v", p.Status())
+	}
+}
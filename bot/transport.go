@@ -0,0 +1,234 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/nlopes/slack"
+)
+
+// Transport connects the Bot to a chat backend.
+//
+// NewSlackTransport adapts the Slack API, package bottest provides an
+// in-memory implementation for testing.
+type Transport interface {
+	// AuthTest identifies the user the bot is running as.
+	AuthTest(ctx context.Context) (*slack.AuthTestResponse, error)
+
+	// PostMessage sends a message to channel. It returns the channel the
+	// message was posted in, which differs from channel for direct
+	// messages to a user, and the timestamp of the message.
+	PostMessage(ctx context.Context, channel string, opts ...slack.MsgOption) (string, string, error)
+
+	// UpdateMessage replaces the message with timestamp in channel.
+	UpdateMessage(ctx context.Context, channel, timestamp string, opts ...slack.MsgOption) error
+
+	// DeleteMessage deletes the message with timestamp in channel.
+	DeleteMessage(ctx context.Context, channel, timestamp string) error
+
+	// PostEphemeral sends a message to channel only visible to user.
+	PostEphemeral(ctx context.Context, channel, user string, opts ...slack.MsgOption) error
+
+	// PostResponse sends msg to a response_url provided with slash commands
+	// and interactions.
+	PostResponse(ctx context.Context, responseURL string, msg slack.Msg) error
+
+	// OpenView opens a modal view in response to an interaction identified
+	// by triggerID.
+	OpenView(ctx context.Context, triggerID string, view View) error
+
+	// AddReaction adds reaction to the message referenced by item.
+	AddReaction(ctx context.Context, reaction string, item slack.ItemRef) error
+
+	// GetFileInfo retrieves information about an uploaded file.
+	GetFileInfo(ctx context.Context, fileID string) (*slack.File, error)
+
+	// GetFile downloads the file at downloadURL into w.
+	GetFile(ctx context.Context, downloadURL string, w io.Writer) error
+
+	EventSource
+}
+
+// EventSource delivers incoming events to the Bot.
+//
+// The event data is one of the slack event types the Bot dispatches on,
+// such as *slack.MessageEvent and *slack.TeamJoinEvent.
+type EventSource interface {
+	// Events returns the stream of incoming events. It is called once
+	// when the Bot is initialized. The source disconnects when ctx is
+	// done.
+	Events(ctx context.Context) <-chan slack.RTMEvent
+}
+
+type slackTransport struct {
+	EventSource
+	client *slack.Client
+	http   *http.Client
+	token  string
+}
+
+// NewSlackTransport creates a Transport backed by the Slack API using the bot
+// token and h for requests. Incoming events are received from src.
+func NewSlackTransport(token string, h *http.Client, src EventSource) Transport {
+	return slackTransport{
+		EventSource: src,
+		client:      slack.New(token, slack.OptionHTTPClient(h)),
+		http:        h,
+		token:       token,
+	}
+}
+
+func (t slackTransport) AuthTest(ctx context.Context) (*slack.AuthTestResponse, error) {
+	resp, err := t.client.AuthTestContext(ctx)
+	return resp, slackCall("auth.test", err)
+}
+
+func (t slackTransport) PostMessage(ctx context.Context, channel string, opts ...slack.MsgOption) (string, string, error) {
+	ch, ts, err := t.client.PostMessageContext(ctx, channel, opts...)
+	return ch, ts, slackCall("chat.postMessage", err)
+}
+
+func (t slackTransport) UpdateMessage(ctx context.Context, channel, timestamp string, opts ...slack.MsgOption) error {
+	_, _, _, err := t.client.UpdateMessageContext(ctx, channel, timestamp, opts...)
+	return slackCall("chat.update", err)
+}
+
+func (t slackTransport) DeleteMessage(ctx context.Context, channel, timestamp string) error {
+	_, _, err := t.client.DeleteMessageContext(ctx, channel, timestamp)
+	return slackCall("chat.delete", err)
+}
+
+func (t slackTransport) PostEphemeral(ctx context.Context, channel, user string, opts ...slack.MsgOption) error {
+	_, err := t.client.PostEphemeralContext(ctx, channel, user, opts...)
+	return slackCall("chat.postEphemeral", err)
+}
+
+// responseURLMessage is the payload accepted by response URLs.
+type responseURLMessage struct {
+	Text            string             `json:"text,omitempty"`
+	Attachments     []slack.Attachment `json:"attachments,omitempty"`
+	Blocks          []slack.Block      `json:"blocks,omitempty"`
+	ResponseType    string             `json:"response_type,omitempty"`
+	ReplaceOriginal bool               `json:"replace_original"`
+	DeleteOriginal  bool               `json:"delete_original,omitempty"`
+}
+
+func (t slackTransport) PostResponse(ctx context.Context, responseURL string, msg slack.Msg) error {
+	return slackCall("response_url", t.postJSON(ctx, responseURL, false, responseURLMessage{
+		Text:            msg.Text,
+		Attachments:     msg.Attachments,
+		Blocks:          msg.Blocks.BlockSet,
+		ResponseType:    msg.ResponseType,
+		ReplaceOriginal: msg.ReplaceOriginal,
+		DeleteOriginal:  msg.DeleteOriginal,
+	}))
+}
+
+func (t slackTransport) OpenView(ctx context.Context, triggerID string, view View) error {
+	return slackCall("views.open", t.postJSON(ctx, slack.APIURL+"views.open", true, struct {
+		TriggerID string `json:"trigger_id"`
+		View      View   `json:"view"`
+	}{triggerID, view}))
+}
+
+// postJSON sends payload to url. Slack Web API responses are checked
+// for errors when api is true.
+func (t slackTransport) postJSON(ctx context.Context, url string, api bool, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if api {
+		req.Header.Set("Authorization", "Bearer "+t.token)
+	}
+
+	resp, err := t.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("posting to %s: non-200 status code: %d", url, resp.StatusCode)
+	}
+	if !api {
+		return nil
+	}
+
+	var apiResp slack.SlackResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return fmt.Errorf("unmarshaling response: %v", err)
+	}
+	return apiResp.Err()
+}
+
+func (t slackTransport) AddReaction(ctx context.Context, reaction string, item slack.ItemRef) error {
+	return slackCall("reactions.add", t.client.AddReactionContext(ctx, reaction, item))
+}
+
+func (t slackTransport) GetFileInfo(ctx context.Context, fileID string) (*slack.File, error) {
+	info, _, _, err := t.client.GetFileInfoContext(ctx, fileID, 0, 0)
+	return info, slackCall("files.info", err)
+}
+
+// GetFile downloads the file itself, the Slack client doesn't provide a
+// variant which can be cancelled with ctx.
+func (t slackTransport) GetFile(ctx context.Context, downloadURL string, w io.Writer) error {
+	return slackCall("files.download", t.download(ctx, downloadURL, w))
+}
+
+func (t slackTransport) download(ctx context.Context, url string, w io.Writer) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+t.token)
+
+	resp, err := t.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("downloading %s: non-200 status code: %d", url, resp.StatusCode)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+type rtmSource struct {
+	client *slack.Client
+	logf   Logger
+}
+
+// NewRTM creates an EventSource which receives events over a Slack RTM
+// connection.
+func NewRTM(c *slack.Client, log Logger) EventSource {
+	return rtmSource{client: c, logf: log}
+}
+
+func (s rtmSource) Events(ctx context.Context) <-chan slack.RTMEvent {
+	rtm := s.client.NewRTM()
+	go rtm.ManageConnection()
+	go func() {
+		<-ctx.Done()
+		if err := rtm.Disconnect(); err != nil {
+			s.logf("disconnecting from RTM: %v\n", err)
+		}
+	}()
+
+	return rtm.IncomingEvents
+}
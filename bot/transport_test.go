@@ -0,0 +1,45 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetFile(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer xoxb-test" {
+			t.Errorf("unexpected authorization: %q", r.Header.Get("Authorization"))
+		}
+		if r.URL.Path == "/hung" {
+			<-release
+		}
+		w.Write([]byte("package main"))
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	transport := NewSlackTransport("xoxb-test", srv.Client(), nil)
+
+	var buf bytes.Buffer
+	if err := transport.GetFile(context.Background(), srv.URL+"/main.go", &buf); err != nil || buf.String() != "package main" {
+		t.Errorf("expected: %q\nactual: %q, %v", "package main", buf.String(), err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- transport.GetFile(ctx, srv.URL+"/hung", &bytes.Buffer{}) }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected the hung download to fail")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the hung download to stop at the deadline")
+	}
+}
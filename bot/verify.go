@@ -0,0 +1,37 @@
+package bot
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/nlopes/slack"
+)
+
+// maxRequestBody limits the size of request bodies accepted from Slack.
+const maxRequestBody = 1 << 20
+
+// readVerifiedBody reads the body of r and verifies it was signed by Slack
+// with secret.
+//
+// https://api.slack.com/authentication/verifying-requests-from-slack
+func readVerifiedBody(r *http.Request, secret string) ([]byte, error) {
+	sv, err := slack.NewSecretsVerifier(r.Header, secret)
+	if err != nil {
+		return nil, fmt.Errorf("creating verifier: %v", err)
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, maxRequestBody))
+	if err != nil {
+		return nil, fmt.Errorf("reading body: %v", err)
+	}
+
+	if _, err := sv.Write(body); err != nil {
+		return nil, fmt.Errorf("hashing body: %v", err)
+	}
+	if err := sv.Ensure(); err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
@@ -0,0 +1,242 @@
+// Package config loads the content of the bot's responses, such as canned
+// responses, reactions and channel lists, from a JSON file so it can be
+// changed without writing Go.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Config is the content of the configuration file.
+type Config struct {
+	// WelcomeChannels are recommended to users joining the team.
+	WelcomeChannels []Channel `json:"welcome_channels"`
+	// RecommendedChannels are listed by the "recommended channels"
+	// command, after WelcomeChannels.
+	RecommendedChannels []Channel `json:"recommended_channels"`
+	// XKCD maps names to comic numbers for the "xkcd" command.
+	XKCD map[string]int `json:"xkcd"`
+	// Responses are commands responding with a canned text.
+	Responses []Response `json:"responses"`
+	// Triggers react or respond to messages containing some text.
+	Triggers []Trigger `json:"triggers"`
+	// DismissReaction is the emoji name, without colons, deleting a bot
+	// reply when its author or a moderator reacts with it.
+	// DefaultDismissReaction when not set.
+	DismissReaction string `json:"dismiss_reaction"`
+}
+
+// DefaultDismissReaction is the DismissReaction of configurations which don't
+// set one.
+const DefaultDismissReaction = "x"
+
+// Channel is a Slack channel recommended to users.
+type Channel struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Response is a command directed to the bot responding with a canned text.
+type Response struct {
+	Prompts     []string `json:"prompts"` // the first one is the name of the command
+	Response    Lines    `json:"response"`
+	Category    string   `json:"category"`
+	Description string   `json:"description"`
+	Ephemeral   bool     `json:"ephemeral"` // only show the response to the user asking
+}
+
+// Trigger reacts or responds to messages which contain Contains, match the
+// regular expression Matches, or start with Prefix. Exactly one of them must
+// be set.
+type Trigger struct {
+	Contains string `json:"contains,omitempty"`
+	Matches  string `json:"matches,omitempty"`
+	Prefix   string `json:"prefix,omitempty"`
+
+	Reactions []string `json:"reactions,omitempty"`
+	Response  string   `json:"response,omitempty"`
+
+	WholeWord   bool     `json:"whole_word,omitempty"`
+	IgnoreCase  bool     `json:"ignore_case,omitempty"`
+	Probability float64  `json:"probability,omitempty"` // between 0 and 1, 0 means always
+	Cooldown    Duration `json:"cooldown,omitempty"`    // per channel
+	Directed    bool     `json:"directed,omitempty"`    // only messages directed to the bot
+}
+
+// Lines is a text which can be written as a list of lines in JSON, which is
+// easier to read than a string with "\n".
+type Lines string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (l *Lines) UnmarshalJSON(b []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("[")) {
+		var lines []string
+		if err := json.Unmarshal(b, &lines); err != nil {
+			return err
+		}
+		*l = Lines(strings.Join(lines, "\n"))
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	*l = Lines(s)
+	return nil
+}
+
+// Duration is a time.Duration written like "10m" in JSON.
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Load decodes and validates a configuration from r.
+func Load(r io.Reader) (*Config, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	c := Config{DismissReaction: DefaultDismissReaction}
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("decoding config: %v", err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// LoadFile loads the configuration from the file at path.
+func LoadFile(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c, err := Load(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+// Validate reports all the problems with c in a single error, or nil if
+// there are none.
+func (c *Config) Validate() error {
+	var problems []string
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	channels := func(field string, chs []Channel) {
+		for i, ch := range chs {
+			if ch.Name == "" {
+				problem("%s[%d]: name is required", field, i)
+			}
+			if strings.HasPrefix(ch.Name, "#") {
+				problem("%s[%d]: name %q must not start with #", field, i, ch.Name)
+			}
+		}
+	}
+	channels("welcome_channels", c.WelcomeChannels)
+	channels("recommended_channels", c.RecommendedChannels)
+
+	for name, n := range c.XKCD {
+		if n <= 0 {
+			problem("xkcd[%q]: comic number must be positive, got %d", name, n)
+		}
+	}
+
+	prompts := make(map[string]int)
+	for i, r := range c.Responses {
+		if len(r.Prompts) == 0 {
+			problem("responses[%d]: prompts are required", i)
+		}
+		for _, p := range r.Prompts {
+			p = strings.ToLower(strings.TrimSpace(p))
+			if p == "" {
+				problem("responses[%d]: prompts must not be empty", i)
+				continue
+			}
+			if j, ok := prompts[p]; ok {
+				problem("responses[%d]: prompt %q is already used by responses[%d]", i, p, j)
+			}
+			prompts[p] = i
+		}
+		if r.Response == "" {
+			problem("responses[%d]: response is required", i)
+		}
+	}
+
+	for i, t := range c.Triggers {
+		var set int
+		for _, s := range []string{t.Contains, t.Matches, t.Prefix} {
+			if s != "" {
+				set++
+			}
+		}
+		if set != 1 {
+			problem("triggers[%d]: exactly one of contains, matches or prefix is required", i)
+		}
+		if t.Matches != "" {
+			if _, err := regexp.Compile(t.Matches); err != nil {
+				problem("triggers[%d]: matches: %v", i, err)
+			}
+		}
+		if t.Prefix != "" && (t.Response != "" || t.WholeWord || t.IgnoreCase || t.Probability != 0 || t.Cooldown != 0) {
+			problem("triggers[%d]: response, whole_word, ignore_case, probability and cooldown aren't supported with prefix", i)
+		}
+		if t.Matches != "" && (t.WholeWord || t.IgnoreCase) {
+			problem("triggers[%d]: use the regular expression instead of whole_word or ignore_case with matches", i)
+		}
+		if (len(t.Reactions) == 0) == (t.Response == "") {
+			problem("triggers[%d]: exactly one of reactions or response is required", i)
+		}
+		for _, r := range t.Reactions {
+			if r == "" || strings.Contains(r, ":") {
+				problem("triggers[%d]: reaction %q must be an emoji name without colons", i, r)
+			}
+		}
+		if t.Probability < 0 || t.Probability > 1 {
+			problem("triggers[%d]: probability must be between 0 and 1, got %v", i, t.Probability)
+		}
+		if t.Cooldown < 0 {
+			problem("triggers[%d]: cooldown must not be negative", i)
+		}
+	}
+
+	if c.DismissReaction == "" || strings.Contains(c.DismissReaction, ":") {
+		problem("dismiss_reaction: %q must be an emoji name without colons", c.DismissReaction)
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid config:\n\t" + strings.Join(problems, "\n\t"))
+	}
+	return nil
+}
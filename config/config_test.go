@@ -0,0 +1,87 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("default file", func(t *testing.T) {
+		c, err := LoadFile("../config.json")
+		if err != nil {
+			t.Fatalf("loading default config: %v", err)
+		}
+		if len(c.Responses) == 0 || len(c.Triggers) == 0 || len(c.WelcomeChannels) == 0 {
+			t.Errorf("expected default config to have content, got %+v", c)
+		}
+	})
+
+	t.Run("decodes lines and durations", func(t *testing.T) {
+		c, err := Load(strings.NewReader(`{
+			"responses": [
+				{"prompts": ["a"], "response": "one line"},
+				{"prompts": ["b"], "response": ["first", "second"]}
+			],
+			"triggers": [{"contains": "bbq", "reactions": ["bbqgopher"], "cooldown": "10m"}]
+		}`))
+		if err != nil {
+			t.Fatalf("loading config: %v", err)
+		}
+		if r := c.Responses[0].Response; r != "one line" {
+			t.Errorf("expected: %q\nactual: %q", "one line", r)
+		}
+		if r := c.Responses[1].Response; r != "first\nsecond" {
+			t.Errorf("expected: %q\nactual: %q", "first\nsecond", r)
+		}
+		if d := time.Duration(c.Triggers[0].Cooldown); d != 10*time.Minute {
+			t.Errorf("expected: %v\nactual: %v", 10*time.Minute, d)
+		}
+		if c.DismissReaction != DefaultDismissReaction {
+			t.Errorf("expected: %q\nactual: %q", DefaultDismissReaction, c.DismissReaction)
+		}
+	})
+
+	t.Run("reports all problems", func(t *testing.T) {
+		_, err := Load(strings.NewReader(`{
+			"welcome_channels": [{"name": "#general"}],
+			"xkcd": {"standards": 0},
+			"responses": [
+				{"prompts": ["books"], "response": "read"},
+				{"prompts": ["Books"]}
+			],
+			"triggers": [
+				{"contains": "bbq", "matches": "bbq", "reactions": [":bbq:"]},
+				{"matches": "(", "response": "x", "probability": 2}
+			],
+			"dismiss_reaction": ":wastebasket:"
+		}`))
+		if err == nil {
+			t.Fatalf("expected error")
+		}
+
+		expected := []string{
+			`welcome_channels[0]: name "#general" must not start with #`,
+			`xkcd["standards"]: comic number must be positive, got 0`,
+			`responses[1]: prompt "books" is already used by responses[0]`,
+			`responses[1]: response is required`,
+			`triggers[0]: exactly one of contains, matches or prefix is required`,
+			`triggers[0]: reaction ":bbq:" must be an emoji name without colons`,
+			`triggers[1]: matches: error parsing regexp`,
+			`triggers[1]: probability must be between 0 and 1, got 2`,
+			`dismiss_reaction: ":wastebasket:" must be an emoji name without colons`,
+		}
+		for _, e := range expected {
+			if !strings.Contains(err.Error(), e) {
+				t.Errorf("expected error to contain: %q\nactual: %v", e, err)
+			}
+		}
+	})
+
+	t.Run("rejects unknown fields", func(t *testing.T) {
+		_, err := Load(strings.NewReader(`{"responses": [{"prompt": ["a"], "response": "b"}]}`))
+		if err == nil || !strings.Contains(err.Error(), `unknown field "prompt"`) {
+			t.Errorf("expected unknown field error, got %v", err)
+		}
+	})
+}
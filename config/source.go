@@ -0,0 +1,114 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"sync"
+
+	"cloud.google.com/go/datastore"
+)
+
+// A Source provides the configuration file, see Load.
+type Source interface {
+	Read(ctx context.Context) ([]byte, error)
+}
+
+// File is a Source reading the file at a path.
+type File string
+
+// Read implements Source.
+func (f File) Read(ctx context.Context) ([]byte, error) {
+	return ioutil.ReadFile(string(f))
+}
+
+// DatastoreSource is a Source reading the configuration from a Google Cloud
+// Platform Datastore entity, so it can be edited in the console without
+// deploying the bot.
+type DatastoreSource struct {
+	ds  *datastore.Client
+	key *datastore.Key
+}
+
+// datastoreConfig is the Datastore entity holding the configuration.
+type datastoreConfig struct {
+	JSON string `datastore:",noindex"`
+}
+
+// NewDatastoreSource creates a DatastoreSource reading the entity of kind
+// "Config" named name.
+func NewDatastoreSource(ds *datastore.Client, name string) *DatastoreSource {
+	return &DatastoreSource{
+		ds:  ds,
+		key: datastore.NameKey("Config", name, nil),
+	}
+}
+
+// Read implements Source.
+func (s *DatastoreSource) Read(ctx context.Context) ([]byte, error) {
+	var c datastoreConfig
+	if err := s.ds.Get(ctx, s.key, &c); err != nil {
+		return nil, err
+	}
+	return []byte(c.JSON), nil
+}
+
+// Reloader loads the configuration from a Source, and again when it changes.
+type Reloader struct {
+	src      Source
+	onChange func(*Config) error
+
+	mu   sync.Mutex
+	last []byte
+}
+
+// NewReloader creates a Reloader calling onChange with the new configuration
+// when Reload finds it changed. onChange returns an error to reject a
+// configuration which is valid, but can't be used.
+func NewReloader(src Source, onChange func(*Config) error) *Reloader {
+	return &Reloader{src: src, onChange: onChange}
+}
+
+// Load loads the configuration, without calling onChange.
+func (r *Reloader) Load(ctx context.Context) (*Config, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, err := r.src.Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Load(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	r.last = b
+	return c, nil
+}
+
+// Reload loads the configuration and calls onChange if it changed since it
+// was last loaded. It reports whether it did. An invalid configuration, or one
+// rejected by onChange, is reported as an error and ignored, so the last
+// valid one stays in use.
+func (r *Reloader) Reload(ctx context.Context) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, err := r.src.Read(ctx)
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(b, r.last) {
+		return false, nil
+	}
+
+	c, err := Load(bytes.NewReader(b))
+	if err != nil {
+		return false, err
+	}
+	if err := r.onChange(c); err != nil {
+		return false, err
+	}
+	r.last = b
+	return true, nil
+}
@@ -34,8 +34,14 @@ func (s *GCPStore) LatestNumber(ctx context.Context) (int, error) {
 	return int(key.ID), err
 }
 
-func (s *GCPStore) Put(ctx context.Context, number int, cl storedCL) error {
-	_, err := s.ds.Put(ctx, s.key(number), &cl)
+func (s *GCPStore) PutMulti(ctx context.Context, cls map[int]storedCL) error {
+	keys := make([]*datastore.Key, 0, len(cls))
+	src := make([]storedCL, 0, len(cls))
+	for number, cl := range cls {
+		keys = append(keys, s.key(number))
+		src = append(src, cl)
+	}
+	_, err := s.ds.PutMulti(ctx, keys, src)
 	return err
 }
 
@@ -9,6 +9,16 @@ import (
 	"io/ioutil"
 	"net/http"
 	"time"
+
+	"github.com/gobridge/gopher/bot"
+	"github.com/gobridge/gopher/metrics"
+)
+
+var (
+	polls = metrics.NewCounterVec("gopher_gerrit_polls_total",
+		"Polls of merged Go CLs, by result.", "result")
+	clsPosted = metrics.NewCounter("gopher_gerrit_cls_posted_total",
+		"Merged Go CLs announced.")
 )
 
 const gerritURL = "https://go-review.googlesource.com/changes/?q=status:merged&O=12&n=100"
@@ -56,13 +66,16 @@ type Gerrit struct {
 	logf   func(message string, args ...interface{})
 	notify func(GerritCL) bool
 
+	// lastID is the number of the newest CL recorded, or -1 before the
+	// first CLs are recorded in an empty store.
 	lastID int
 }
 
 // Store persists information about CLs that have been handled.
 type Store interface {
 	LatestNumber(context.Context) (int, error)
-	Put(_ context.Context, number int, _ storedCL) error
+	// PutMulti records the CLs of a poll, by number.
+	PutMulti(_ context.Context, cls map[int]storedCL) error
 	Exists(_ context.Context, number int) (bool, error)
 }
 
@@ -90,32 +103,40 @@ func New(ctx context.Context, s Store, http *http.Client, logf func(message stri
 	}, nil
 }
 
-// Poll checks for new merged CLs and calls notify for each CL.
-func (g *Gerrit) Poll(ctx context.Context) {
+// Poll checks for new merged CLs and calls notify for each CL. It returns an
+// error when Gerrit can't be reached or a new CL can't be announced.
+//
+// When the store is empty, such as with a MemoryStore after a restart, the
+// first CLs are recorded without being announced, since they were merged
+// before the bot started.
+func (g *Gerrit) Poll(ctx context.Context) (err error) {
+	ctx, span := bot.StartSpan(ctx, "Gerrit.Poll")
+	defer span.End()
+	defer func() {
+		bot.SpanError(span, err)
+		polls.WithLabelValues(metrics.Result(err)).Inc()
+	}()
+
 	req, err := http.NewRequest("GET", gerritURL, nil)
 	if err != nil {
-		g.logf("failed to build GET request to %q: %v\n", gerritURL, err)
-		return
+		return fmt.Errorf("failed to build GET request to %q: %v", gerritURL, err)
 	}
 	req.Header.Add("User-Agent", "Gophers Slack bot")
 	req = req.WithContext(ctx)
 
 	resp, err := g.http.Do(req)
 	if err != nil {
-		g.logf("failed to get data from Gerrit: %v\n", err)
-		return
+		return fmt.Errorf("failed to get data from Gerrit: %v", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		g.logf("got non-200 code: %d from gerrit api", resp.StatusCode)
-		return
+		return fmt.Errorf("got non-200 code: %d from gerrit api", resp.StatusCode)
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		g.logf("reading body: %v", err)
-		return
+		return fmt.Errorf("reading body: %v", err)
 	}
 	// Gerrit prefixes responses with `)]}'`
 	// https://gerrit-review.googlesource.com/Documentation/rest-api.html#output
@@ -124,8 +145,7 @@ func (g *Gerrit) Poll(ctx context.Context) {
 	var cls []GerritCL
 	err = json.Unmarshal(body, &cls)
 	if err != nil {
-		g.logf("unmarshaling response: %v\n", err)
-		return
+		return fmt.Errorf("unmarshaling response: %v", err)
 	}
 
 	// The change output is sorted by the last update time, most recently updated to oldest updated.
@@ -137,6 +157,12 @@ func (g *Gerrit) Poll(ctx context.Context) {
 		}
 	}
 
+	// The new CLs are recorded at once, before being announced oldest first
+	// so they're announced at most once.
+	var (
+		newCLs []GerritCL
+		stored = make(map[int]storedCL)
+	)
 	for i := len(cls) - 1; i >= 0; i-- {
 		cl := cls[i]
 
@@ -149,20 +175,37 @@ func (g *Gerrit) Poll(ctx context.Context) {
 			continue
 		}
 
-		err = g.store.Put(ctx, cl.Number, storedCL{
+		newCLs = append(newCLs, cl)
+		stored[cl.Number] = storedCL{
 			URL:       cl.Link(),
 			Message:   cl.Message(),
 			CrawledAt: time.Now(),
-		})
-		if err != nil {
-			g.logf("saving CL to datastore: %v", err)
-			return
 		}
+	}
+	if len(newCLs) == 0 {
+		return nil
+	}
 
+	if err := g.store.PutMulti(ctx, stored); err != nil {
+		return fmt.Errorf("saving CLs to datastore: %v", err)
+	}
+	seeding := g.lastID < 0
+	g.lastID = newCLs[len(newCLs)-1].Number
+	if seeding {
+		g.logf("recorded %d merged CLs without announcing them", len(newCLs))
+		return nil
+	}
+
+	var failed []int
+	for _, cl := range newCLs {
 		if !g.notify(cl) {
-			return
+			failed = append(failed, cl.Number)
+			continue
 		}
-
-		g.lastID = cl.Number
+		clsPosted.Inc()
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("announcing CLs %v failed", failed)
 	}
+	return nil
 }
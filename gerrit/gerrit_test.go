@@ -0,0 +1,72 @@
+package gerrit
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// roundTripFunc serves the requests of an http.Client.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestPoll(t *testing.T) {
+	// merged are the CLs returned by Gerrit, most recently updated first.
+	var merged []GerritCL
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		b, err := json.Marshal(merged)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader(")]}'\n" + string(b))),
+		}, nil
+	})}
+	cls := func(numbers ...int) []GerritCL {
+		cls := make([]GerritCL, len(numbers))
+		for i, n := range numbers {
+			cls[i] = GerritCL{Project: "go", Number: n}
+		}
+		return cls
+	}
+
+	ctx := context.Background()
+	store := NewMemoryStore()
+	var announced []int
+	g, err := New(ctx, store, client, t.Logf, func(cl GerritCL) bool {
+		announced = append(announced, cl.Number)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("creating poller: %v", err)
+	}
+
+	// The first poll of an empty store records the CLs already merged.
+	merged = cls(3, 2, 1)
+	if err := g.Poll(ctx); err != nil {
+		t.Fatalf("first poll: %v", err)
+	}
+	if announced != nil {
+		t.Errorf("expected no CL to be announced, got %v", announced)
+	}
+	if n, err := store.LatestNumber(ctx); err != nil || n != 3 {
+		t.Errorf("expected: 3\nactual: %d, %v", n, err)
+	}
+
+	// Later polls announce the new CLs, oldest first.
+	merged = cls(5, 4, 3, 2, 1)
+	if err := g.Poll(ctx); err != nil {
+		t.Fatalf("second poll: %v", err)
+	}
+	if expected := []int{4, 5}; !reflect.DeepEqual(expected, announced) {
+		t.Errorf("expected: %v\nactual: %v", expected, announced)
+	}
+}
@@ -0,0 +1,143 @@
+package gerrit
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// maxLocalCLs is how many CLs MemoryStore and FileStore keep, the most
+// recently crawled ones. It's several times the CLs returned by a poll, which
+// is all LatestNumber and Exists need to know about.
+const maxLocalCLs = 500
+
+// MemoryStore implements Store and tracks state in memory, which is lost when
+// the bot restarts.
+type MemoryStore struct {
+	mu  sync.Mutex
+	cls map[int]storedCL
+}
+
+// NewMemoryStore constructs a new *MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{cls: make(map[int]storedCL)}
+}
+
+func (s *MemoryStore) LatestNumber(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	latest := -1
+	for number, cl := range s.cls {
+		if latest < 0 || cl.CrawledAt.After(s.cls[latest].CrawledAt) {
+			latest = number
+		}
+	}
+	if latest < 0 {
+		return 0, ErrNotFound
+	}
+	return latest, nil
+}
+
+func (s *MemoryStore) PutMulti(ctx context.Context, cls map[int]storedCL) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.putMulti(cls)
+	return nil
+}
+
+// putMulti adds cls, then forgets the least recently crawled CLs beyond
+// maxLocalCLs. s.mu must be held.
+func (s *MemoryStore) putMulti(cls map[int]storedCL) {
+	for number, cl := range cls {
+		s.cls[number] = cl
+	}
+	if len(s.cls) <= maxLocalCLs {
+		return
+	}
+
+	numbers := make([]int, 0, len(s.cls))
+	for number := range s.cls {
+		numbers = append(numbers, number)
+	}
+	sort.Slice(numbers, func(i, j int) bool {
+		return s.cls[numbers[i]].CrawledAt.Before(s.cls[numbers[j]].CrawledAt)
+	})
+	for _, number := range numbers[:len(numbers)-maxLocalCLs] {
+		delete(s.cls, number)
+	}
+}
+
+func (s *MemoryStore) Exists(ctx context.Context, number int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.cls[number]
+	return ok, nil
+}
+
+// FileStore implements Store and tracks state in a JSON file, for running the
+// bot without Google Cloud Platform.
+type FileStore struct {
+	*MemoryStore
+	path string
+}
+
+// NewFileStore constructs a new *FileStore persisting state to the file at
+// path, which is created if it doesn't exist.
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{MemoryStore: NewMemoryStore(), path: path}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cls map[int]storedCL
+	if err := json.Unmarshal(b, &cls); err != nil {
+		return nil, err
+	}
+	s.putMulti(cls)
+	return s, nil
+}
+
+// PutMulti adds cls and rewrites the file once.
+func (s *FileStore) PutMulti(ctx context.Context, cls map[int]storedCL) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.putMulti(cls)
+	return s.save()
+}
+
+// save writes the CLs to a temporary file replacing the file at s.path, so the
+// file is never left half written.
+func (s *FileStore) save() error {
+	b, err := json.MarshalIndent(s.cls, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.path)
+}
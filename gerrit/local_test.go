@@ -0,0 +1,82 @@
+package gerrit
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gerrit")
+	if err != nil {
+		t.Fatalf("creating directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cls.json")
+	ctx := context.Background()
+
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("creating store: %v", err)
+	}
+	if _, err := s.LatestNumber(ctx); err != ErrNotFound {
+		t.Errorf("expected: %v\nactual: %v", ErrNotFound, err)
+	}
+
+	now := time.Now()
+	err = s.PutMulti(ctx, map[int]storedCL{
+		12: {CrawledAt: now},
+		10: {CrawledAt: now.Add(time.Minute)},
+		11: {CrawledAt: now.Add(2 * time.Minute)},
+	})
+	if err != nil {
+		t.Fatalf("putting CLs: %v", err)
+	}
+
+	// A new store reads what the previous one saved.
+	s, err = NewFileStore(path)
+	if err != nil {
+		t.Fatalf("reopening store: %v", err)
+	}
+	if n, err := s.LatestNumber(ctx); err != nil || n != 11 {
+		t.Errorf("expected: 11\nactual: %d, %v", n, err)
+	}
+	if ok, err := s.Exists(ctx, 10); err != nil || !ok {
+		t.Errorf("expected CL 10 to exist, got %t, %v", ok, err)
+	}
+	if ok, err := s.Exists(ctx, 13); err != nil || ok {
+		t.Errorf("expected CL 13 not to exist, got %t, %v", ok, err)
+	}
+}
+
+func TestMemoryStoreWindow(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+
+	now := time.Now()
+	for poll := 0; poll < 10; poll++ {
+		cls := make(map[int]storedCL)
+		for i := 0; i < 100; i++ {
+			number := poll*100 + i
+			cls[number] = storedCL{CrawledAt: now.Add(time.Duration(number) * time.Second)}
+		}
+		if err := s.PutMulti(ctx, cls); err != nil {
+			t.Fatalf("putting CLs: %v", err)
+		}
+	}
+
+	if len(s.cls) != maxLocalCLs {
+		t.Errorf("expected: %d CLs\nactual: %d", maxLocalCLs, len(s.cls))
+	}
+	if n, err := s.LatestNumber(ctx); err != nil || n != 999 {
+		t.Errorf("expected: 999\nactual: %d, %v", n, err)
+	}
+	for number, expected := range map[int]bool{499: false, 500: true, 999: true} {
+		if ok, _ := s.Exists(ctx, number); ok != expected {
+			t.Errorf("CL %d: expected exists: %t\nactual: %t", number, expected, ok)
+		}
+	}
+}
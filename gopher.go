@@ -25,6 +25,8 @@ package main
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -32,22 +34,64 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gobridge/gopher/bot"
+	"github.com/gobridge/gopher/config"
 	"github.com/gobridge/gopher/gerrit"
 	"github.com/gobridge/gopher/gotime"
 	"github.com/gobridge/gopher/handlers"
+	"github.com/gobridge/gopher/health"
 
 	"cloud.google.com/go/datastore"
-	"cloud.google.com/go/trace"
 	"github.com/nlopes/slack"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 	"google.golang.org/api/option"
 )
 
 const defaultCredentialFile = "/tmp/trace/trace.json" // Also /tmp/datastore/datastore.json :-(
 
+// defaultConfigFile is the configuration used when GOPHERS_CONFIG isn't set.
+const defaultConfigFile = "config.json"
+
+// defaultStorageDir is where state is kept with GOPHERS_STORAGE=file when
+// GOPHERS_STORAGE_DIR isn't set.
+const defaultStorageDir = "data"
+
+// configPollInterval is how often the configuration is checked for changes.
+const configPollInterval = 30 * time.Second
+
+// shutdownTimeout is how long in-flight work may take to finish on shutdown,
+// Heroku and Kubernetes kill the process 30 seconds after SIGTERM.
+const shutdownTimeout = 25 * time.Second
+
+// handlerTimeout is how long handlers may take to respond to a message.
+const handlerTimeout = 30 * time.Second
+
+// healthCheckInterval is how often the Slack connection, the workers and
+// the storage are checked.
+const healthCheckInterval = time.Minute
+
+// parallelHandlers is how many handlers may process a message concurrently.
+const parallelHandlers = 8
+
 var BotVersion = "HEAD"
 
 func main() {
@@ -56,12 +100,35 @@ func main() {
 	log.SetFlags(log.Lshortfile)
 	logf := log.Printf
 
+	// exitCode is the status of the process when it isn't 0, set once every
+	// deferred cleanup ran.
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
 	var (
 		slackBotToken     = os.Getenv("GOPHERS_SLACK_BOT_TOKEN")
+		slackEventsMode   = os.Getenv("GOPHERS_SLACK_BOT_EVENTS")
+		slackSecret       = os.Getenv("GOPHERS_SLACK_SIGNING_SECRET")
+		slackAppToken     = os.Getenv("GOPHERS_SLACK_APP_TOKEN")
 		googleCredentials = os.Getenv("GOOGLE_CREDENTIALS")
 		googleProjectID   = os.Getenv("GOOGLE_PROJECT_ID")
 		opsChannel        = os.Getenv("OPS_CHANNEL")
+		moderators        = os.Getenv("GOPHERS_SLACK_MODERATORS")
+		configFile        = os.Getenv("GOPHERS_CONFIG")
+		configEntity      = os.Getenv("GOPHERS_CONFIG_DATASTORE")
+		tracing           = os.Getenv("GOPHERS_TRACING")
+		otlpEndpoint      = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		storage           = os.Getenv("GOPHERS_STORAGE")
+		storageDir        = os.Getenv("GOPHERS_STORAGE_DIR")
+		workers           = os.Getenv("GOPHERS_SLACK_BOT_WORKERS")
+		queueSize         = os.Getenv("GOPHERS_SLACK_BOT_QUEUE_SIZE")
 		devMode           = os.Getenv("GOPHERS_SLACK_BOT_DEV_MODE") == "true"
+		exitWhenStuck     = os.Getenv("GOPHERS_EXIT_WHEN_STUCK") == "true"
+		commandsInChannel = os.Getenv("GOPHERS_SLACK_COMMANDS_IN_CHANNEL") == "true"
 	)
 
 	if slackBotToken == "" {
@@ -78,248 +145,230 @@ func main() {
 		googleCredentials = decodeGoogleCredentialsToFile(googleCredentials)
 	}
 
-	ctx := context.Background()
+	// ctx is cancelled on SIGINT or SIGTERM, which stops receiving events
+	// and polling. Work in progress uses workCtx and may finish until
+	// shutdownTimeout passes.
+	ctx, cancel := context.WithCancel(context.Background())
+	workCtx, cancelWork := context.WithCancel(context.Background())
+	defer cancelWork()
+
+	// Google Cloud Platform is only used when configured, so the bot can run
+	// locally without credentials.
+	if tracing == "" {
+		tracing = "none"
+		if otlpEndpoint != "" {
+			tracing = "otlp"
+		}
+	}
+	if storage == "" {
+		storage = "file"
+		if googleProjectID != "" {
+			storage = "datastore"
+		}
+	}
+	if storageDir == "" {
+		storageDir = defaultStorageDir
+	}
 
-	traceClient, err := trace.NewClient(ctx, googleProjectID, option.WithServiceAccountFile(googleCredentials))
-	if err != nil {
-		log.Fatalln("Unable to create trace client:", err)
+	var tracerProvider *sdktrace.TracerProvider
+	switch tracing {
+	case "otlp", "stdout":
+		tp, err := newTracerProvider(ctx, tracing)
+		if err != nil {
+			log.Fatalln("Unable to create tracer:", err)
+		}
+		tracerProvider = tp
+		otel.SetTracerProvider(tp)
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	case "none":
+		// Without a TracerProvider, spans aren't recorded.
+	default:
+		log.Fatalf("unknown GOPHERS_TRACING %q, must be otlp, stdout or none", tracing)
+	}
+	ctx, span := bot.StartSpan(ctx, "main")
+
+	// dsClient is created by datastoreClient the first time it's needed.
+	var dsClient *datastore.Client
+	datastoreClient := func() *datastore.Client {
+		if dsClient == nil {
+			c, err := datastore.NewClient(ctx, googleProjectID, option.WithServiceAccountFile(googleCredentials))
+			if err != nil {
+				log.Fatalln("Unable to create datastore client:", err)
+			}
+			dsClient = c
+		}
+		return dsClient
 	}
-	span := traceClient.NewSpan("main")
-	ctx = trace.NewContext(ctx, span)
+	defer func() {
+		if dsClient != nil {
+			dsClient.Close()
+		}
+	}()
 
-	dsClient, err := datastore.NewClient(ctx, googleProjectID, option.WithServiceAccountFile(googleCredentials))
+	var gerritStore gerrit.Store
+	switch storage {
+	case "datastore":
+		gerritStore = gerrit.NewGCPStore(datastoreClient())
+	case "file":
+		if err := os.MkdirAll(storageDir, 0755); err != nil {
+			log.Fatalln("Unable to create storage directory:", err)
+		}
+		s, err := gerrit.NewFileStore(filepath.Join(storageDir, "gerrit.json"))
+		if err != nil {
+			log.Fatalln("Unable to open gerrit store:", err)
+		}
+		gerritStore = s
+	case "memory":
+		gerritStore = gerrit.NewMemoryStore()
+	default:
+		log.Fatalf("unknown GOPHERS_STORAGE %q, must be datastore, file or memory", storage)
+	}
+
+	// The configuration is reloaded when it changes, replacing the handlers
+	// of the bot.
+	if configFile == "" {
+		configFile = defaultConfigFile
+	}
+	var configSource config.Source = config.File(configFile)
+	if configEntity != "" {
+		configSource = config.NewDatastoreSource(datastoreClient(), configEntity)
+	}
+	var (
+		b             *bot.Bot
+		reloader      *config.Reloader
+		buildHandlers func(*config.Config) (bot.Handler, bot.JoinHandler, bot.ReactionHandler, error)
+	)
+	reloader = config.NewReloader(configSource, func(cfg *config.Config) error {
+		msgHandlers, joinHandler, reactionHandler, err := buildHandlers(cfg)
+		if err != nil {
+			return err
+		}
+		b.SetHandlers(msgHandlers, joinHandler, reactionHandler)
+		logf("Reloaded config")
+		return nil
+	})
+	cfg, err := reloader.Load(ctx)
 	if err != nil {
-		log.Fatalln("Unable to create datastore client:", err)
+		log.Fatalln("Unable to load config:", err)
 	}
-	defer dsClient.Close()
 
 	traceHTTPClient := &http.Client{
-		Transport: trace.Transport{
-			Base: &http.Transport{
-				Dial: (&net.Dialer{
-					Timeout:   15 * time.Second,
-					KeepAlive: 30 * time.Second,
-				}).Dial,
-				TLSHandshakeTimeout:   5 * time.Second,
-				ResponseHeaderTimeout: 10 * time.Second,
-				ExpectContinueTimeout: 1 * time.Second,
-			},
-		},
+		Transport: otelhttp.NewTransport(&http.Transport{
+			Dial: (&net.Dialer{
+				Timeout:   15 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).Dial,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ResponseHeaderTimeout: 10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		}),
 	}
 	slackBotAPI := slack.New(slackBotToken,
 		slack.OptionHTTPClient(traceHTTPClient),
 	)
 
-	welcomeChannels := []handlers.Channel{
-		{Name: "general", Description: "for general Go questions or help"},
-		{Name: "newbies", Description: "for newbie resources"},
-		{Name: "reviews", Description: "for code reviews"},
-		{Name: "gotimefm", Description: "for the awesome live podcast"},
-		{Name: "remotemeetup", Description: "for remote meetup"},
-		{Name: "showandtell", Description: "for telling the world about the thing you are working on"},
-		{Name: "jobs", Description: "for jobs related to Go"},
-	}
-	recommendedChannels := append(welcomeChannels, []handlers.Channel{
-		{Name: "performance", Description: "anything and everything performance related"},
-		{Name: "devops", Description: "for devops related discussions"},
-		{Name: "security", Description: "for security related discussions"},
-		{Name: "aws", Description: "if you are interested in AWS"},
-		{Name: "goreviews", Description: "talk to the Go team about a certain CL"},
-		{Name: "golang-cls", Description: "get real time udates from the merged CL for Go itself"},
-		{Name: "bbq", Description: "Go controlling your bbq grill? Yes, we have that"},
-	}...)
-
-	joinHandler := handlers.Join(welcomeChannels)
+	mux := http.NewServeMux()
 
-	msgHandlers := handlers.ProcessLinear(
-		handlers.ReactWhenContains("my adorable little gophers", "gopher"),
-		handlers.ReactWhenContains("bbq", "bbqgopher"),
-		handlers.ReactWhenContains("buffalo", "gobuffalo"),
-		handlers.ReactWhenContains("gobuffalo", "gobuffalo"),
-		handlers.ReactWhenContains("ghost", "ghost"),
-		handlers.ReactWhenContains("ermergerd", "dragon"),
-		handlers.ReactWhenContains("ermahgerd", "dragon"),
-		handlers.ReactWhenContains("dragon", "dragon"),
-		handlers.ReactWhenContains("spacex", "rocket"),
-		handlers.ReactWhenContains("beer me", "beer", "beers"),
-		handlers.ReactWhenContains("spacemacs", "spacemacs"),
-		handlers.ReactWhenContainsRand("emacs", "vim"),
-		handlers.ReactWhenContainsRand("vim", "emacs"),
-		handlers.RespondWhenContains("︵", "┬─┬ノ( º _ ºノ)"),
-		handlers.RespondWhenContains("彡", "┬─┬ノ( º _ ºノ)"),
-
-		handlers.Songs(), // TODO: Is this used?
-		handlers.SuggestPlayground(traceHTTPClient, slackBotAPI, logf, 10),
-		handlers.LinkToGoDoc("d/", "https://godoc.org/"),
-		handlers.LinkToGoDoc("ghd/", "https://godoc.org/github.com/"),
-
-		handlers.WhenDirectedToBot(handlers.ProcessLinear(
-			handlers.ReactWhenContains("thank", "gopher"),
-			handlers.ReactWhenContains("cheers", "gopher"),
-			handlers.ReactWhenContains("hello", "gopher"),
-			handlers.ReactWhenHasPrefix("wave", "wave", "gopher"),
-			handlers.BotStack([]string{"stack", "where do you live?"}),
-			handlers.BotVersion("version", BotVersion),
-			handlers.CoinFlip([]string{"coin flip", "flip a coin"}),
-			handlers.RecommendedChannels("recommended channels", recommendedChannels),
-			handlers.NewbieResources("newbie resources"),
-			handlers.SearchForLibrary("library for"),
-			handlers.XKCD("xkcd:",
-				map[string]int{
-					"standards":    927,
-					"compiling":    303,
-					"optimization": 1691,
-				},
-				logf,
-			),
-
-			handlers.RespondTo([]string{"recommended", "recommended blogs"},
-				strings.Join([]string{
-					`Here are some popular blog posts and Twitter accounts you should follow:`,
-					`- Peter Bourgon <https://twitter.com/peterbourgon|@peterbourgon> - <https://peter.bourgon.org/blog>`,
-					`- Carlisia Campos <https://twitter.com/carlisia|@carlisia>`,
-					`- Dave Cheney <https://twitter.com/davecheney|@davecheney> - <http://dave.cheney.net>`,
-					`- Jaana Burcu Dogan <https://twitter.com/rakyll|@rakyll> - <http://golang.rakyll.org>`,
-					`- Jessie Frazelle <https://twitter.com/jessfraz|@jessfraz> - <https://blog.jessfraz.com>`,
-					`- William "Bill" Kennedy <https://twitter.com|@goinggodotnet> - <https://www.goinggo.net>`,
-					`- Brian Ketelsen <https://twitter.com/bketelsen|@bketelsen> - <https://www.brianketelsen.com/blog>`,
-				}, "\n"),
-			),
-			handlers.RespondTo([]string{"books"},
-				strings.Join([]string{
-					`Here are some popular books you can use to get started:`,
-					`- William Kennedy, Brian Ketelsen, Erik St. Martin Go In Action <https://www.manning.com/books/go-in-action>`,
-					`- Alan A A Donovan, Brian W Kernighan The Go Programming Language <https://www.gopl.io>`,
-					`- Mat Ryer Go Programming Blueprints 2nd Edition <https://www.packtpub.com/application-development/go-programming-blueprints-second-edition>`,
-				}, "\n"),
-			),
-			handlers.RespondTo([]string{"oss help", "oss help wanted"},
-				`Here's a list of projects which could need some help from contributors like you: <https://github.com/corylanou/oss-helpwanted>`,
-			),
-			handlers.RespondTo([]string{"work with forks", "working with forks"},
-				`Here's how to work with package forks in Go: <http://blog.sgmansfield.com/2016/06/working-with-forks-in-go/>`,
-			),
-			handlers.RespondTo([]string{"block forever", "how to block forever"},
-				`Here's how to block forever in Go: <http://blog.sgmansfield.com/2016/06/how-to-block-forever-in-go/>`,
-			),
-			handlers.RespondTo([]string{"http timeouts"},
-				`Here's a blog post which will help with http timeouts in Go: <https://blog.cloudflare.com/the-complete-guide-to-golang-net-http-timeouts/>`,
-			),
-			handlers.RespondTo([]string{"slices", "slice internals"},
-				strings.Join([]string{
-					`The following posts will explain how slices, maps and strings work in Go:`,
-					`- <https://blog.golang.org/go-slices-usage-and-internals>`,
-					`- <https://blog.golang.org/slices>`,
-					`- <https://blog.golang.org/strings>`,
-				}, "\n"),
-			),
-			handlers.RespondTo([]string{"databases", "database tutorial"},
-				`Here's how to work with database/sql in Go: <http://go-database-sql.org/>`,
-			),
-			handlers.RespondTo(
-				[]string{
-					"project layout",
-					"package layout",
-					"project structure",
-					"package structure",
-				},
-				strings.Join([]string{
-					`These articles will explain how to organize your Go packages:`,
-					`- <https://rakyll.org/style-packages/>`,
-					`- <https://medium.com/@benbjohnson/standard-package-layout-7cdbc8391fc1#.ds38va3pp>`,
-					`- <https://peter.bourgon.org/go-best-practices-2016/#repository-structure>`,
-					``,
-					`This article will help you understand the design philosophy for packages: <https://www.goinggo.net/2017/02/design-philosophy-on-packaging.html>`,
-				}, "\n"),
-			),
-			handlers.RespondTo([]string{"idiomatic go"},
-				`Tips on how to write idiomatic Go code <https://dmitri.shuralyov.com/idiomatic-go>`,
-			),
-			handlers.RespondTo([]string{"gotchas", "avoid gotchas"},
-				`Read this article if you want to understand and avoid common gotchas in Go <https://divan.github.io/posts/avoid_gotchas>`,
-			),
-			handlers.RespondTo([]string{"style", "style guide"},
-				`Here is the Go style guide by Uber: <https://github.com/uber-go/guide/blob/master/style.md>`,
-			),
-			handlers.RespondTo([]string{"source", "source code"},
-				`My source code is here <https://github.com/gobridge/gopher>`,
-			),
-			handlers.RespondTo([]string{"di", "dependency injection"},
-				strings.Join([]string{
-					`If you'd like to learn more about how to use Dependency Injection in Go, please review this post:`,
-					`- <https://appliedgo.net/di/>`,
-				}, "\n"),
-			),
-			handlers.RespondTo([]string{"pointer performance"},
-				strings.Join([]string{
-					`The answer to whether using a pointer offers a performance gain is complex and is not always the case. Please read these posts for more information:`,
-					`- <https://medium.com/@vCabbage/go-are-pointers-a-performance-optimization-a95840d3ef85>`,
-					`- <https://segment.com/blog/allocation-efficiency-in-high-performance-go-services/>`,
-				}, "\n"),
-			),
-			handlers.RespondTo([]string{"help"},
-				strings.Join([]string{
-					`Here's a list of supported commands`,
-					"- `newbie resources` -> get a list of newbie resources",
-					"- `newbie resources pvt` -> get a list of newbie resources as a private message",
-					"- `recommended channels` -> get a list of recommended channels",
-					"- `oss help` -> help the open-source community",
-					"- `work with forks` -> how to work with forks of packages",
-					"- `idiomatic go` -> learn how to write more idiomatic Go code",
-					"- `block forever` -> how to block forever",
-					"- `http timeouts` -> tutorial about dealing with timeouts and http",
-					"- `database tutorial` -> tutorial about using sql databases",
-					"- `package layout` -> learn how to structure your Go package",
-					"- `avoid gotchas` -> avoid common gotchas in Go",
-					"- `library for <name>` -> search a go package that matches <name>",
-					"- `flip a coin` -> flip a coin",
-					"- `source code` -> location of my source code",
-					"- `where do you live?` OR `stack` -> get information about where the tech stack behind @gopher",
-				}, "\n"),
-			),
-			handlers.RespondTo(
-				[]string{
-					"gopath",
-					"gopath problem",
-					"issue with gopath",
-					"help with gopath",
-				},
-				strings.Join([]string{
-					"Your project should be structured as follows:",
-					"```GOPATH=~/go",
-					"~/go/src/sourcecontrol/username/project/```",
-					"Whilst you _can_ get around the GOPATH, it's ill-advised. Read more about the GOPATH here: https://github.com/golang/go/wiki/GOPATH",
-				}, "\n"),
-			),
-		)),
+	var (
+		events     bot.EventSource
+		socketMode *bot.SocketMode
 	)
+	switch slackEventsMode {
+	case "", "rtm":
+		events = bot.NewRTM(slackBotAPI, logf)
+	case "events":
+		if slackSecret == "" {
+			log.Fatalln("slack signing secret must be set in GOPHERS_SLACK_SIGNING_SECRET to use the events API")
+		}
+		eventsAPI := bot.NewEventsAPI(slackSecret, logf)
+		mux.Handle("/slack/events", eventsAPI)
+		events = eventsAPI
+	case "socket":
+		if slackAppToken == "" {
+			log.Fatalln("slack app token must be set in GOPHERS_SLACK_APP_TOKEN to use socket mode")
+		}
+		socketMode = bot.NewSocketMode(slackAppToken, traceHTTPClient, logf)
+		events = socketMode
+	default:
+		log.Fatalf("unknown GOPHERS_SLACK_BOT_EVENTS mode %q, must be rtm, events or socket", slackEventsMode)
+	}
+
+	transport := bot.NewSlackTransport(slackBotToken, traceHTTPClient, events)
+	moderatorIDs := splitList(moderators)
+	// Slash commands and interactions are received over HTTP when the
+	// signing secret is set, and over the connection in socket mode.
+	interactive := slackSecret != "" || slackEventsMode == "socket"
+	buildHandlers = func(cfg *config.Config) (bot.Handler, bot.JoinHandler, bot.ReactionHandler, error) {
+		return newHandlers(cfg, traceHTTPClient, transport, moderatorIDs, interactive, reloader.Reload, logf)
+	}
+	msgHandlers, joinHandler, reactionHandler, err := buildHandlers(cfg)
+	if err != nil {
+		log.Fatalln("Unable to load config:", err)
+	}
+
+	b = bot.New(transport, devMode, logf, msgHandlers, joinHandler, reactionHandler)
+	if workers != "" || queueSize != "" {
+		b.SetWorkers(
+			envInt("GOPHERS_SLACK_BOT_WORKERS", workers, bot.DefaultWorkers),
+			envInt("GOPHERS_SLACK_BOT_QUEUE_SIZE", queueSize, bot.DefaultQueueSize),
+		)
+	}
+	if err := bot.RegisterStats(prometheus.DefaultRegisterer, b); err != nil {
+		logf("registering event metrics: %v", err)
+	}
+
+	// Subsystems report into checks, /healthz fails when a critical one
+	// doesn't succeed for too long so the bot is restarted.
+	checks := health.NewRegistry()
+	slackCheck := checks.Register("slack", 5*time.Minute, true)
+	workersCheck := checks.Register("workers", 5*time.Minute, true)
+	storageCheck := checks.Register("storage", 15*time.Minute, false)
+	// The Events API has no such check: Slack doesn't call the endpoint
+	// when nothing happens, so a quiet workspace can't be told apart from a
+	// broken subscription.
+	switch {
+	case socketMode != nil:
+		socketMode.SetCheck(checks.Register("slack.socket", 5*time.Minute, true))
+	case slackEventsMode == "" || slackEventsMode == "rtm":
+		b.SetEventsCheck(checks.Register("slack.rtm", 2*time.Minute, true))
+	}
+
+	interactions := handlers.RouteInteractions(map[string]bot.InteractionHandler{
+		handlers.DismissAction: handlers.DismissMessage(moderatorIDs, logf),
+	})
+	b.SetRequestHandlers(commandsInChannel, interactions)
 
-	b := bot.New(slackBotAPI, traceClient, devMode, logf, msgHandlers, joinHandler)
 	err = b.Init(ctx)
 	if err != nil {
 		log.Fatalln("Unable to init bot:", err)
 	}
+	if slackSecret != "" {
+		mux.Handle("/slack/commands", b.SlashCommands(slackSecret, commandsInChannel))
+		mux.Handle("/slack/interactions", b.Interactions(slackSecret, interactions))
+	}
 	if opsChannel != "" {
-		cs := span.NewChild("main.AnnouncingStartupFinish")
+		ctx, cs := bot.StartSpan(ctx, "main.AnnouncingStartupFinish")
 		err = b.PostMessage(ctx, opsChannel, `Deployed version: `+BotVersion)
-		cs.Finish()
+		cs.End()
 		if err != nil {
 			logf(`failed to deploy version: %s`, BotVersion)
 		}
 	}
 
+	// pollers tracks the pollers, which finish their current poll on
+	// shutdown.
+	var pollers sync.WaitGroup
+
 	// Gerrit CL Notifications
 	if !devMode {
 		notify := func(cl gerrit.GerritCL) bool {
 			msg := fmt.Sprintf("[%d] %s: %s", cl.Number, cl.Message(), cl.Link())
-			err = b.PostMessage(ctx, "golang-cls", msg,
-				slack.MsgOptionAttachments(slack.Attachment{
-					Title:     cl.Subject,
-					TitleLink: cl.Link(),
-					Text:      cl.Revisions[cl.CurrentRevision].Commit.Message,
-					Footer:    cl.ChangeID,
-				}),
-			)
+			blocks := bot.NewBlocks().
+				Section(fmt.Sprintf("*<%s|[%d] %s>*", cl.Link(), cl.Number, cl.Message())).
+				Section(cl.Revisions[cl.CurrentRevision].Commit.Message).
+				Context(cl.ChangeID).
+				Blocks()
+			err := b.PostMessage(workCtx, "golang-cls", msg, slack.MsgOptionBlocks(blocks...))
 			if err != nil {
 				logf("error posting to #golang-cls: %v", err)
 				return false
@@ -327,35 +376,46 @@ func main() {
 			return true
 		}
 
-		dsClient, err := datastore.NewClient(ctx, googleProjectID, option.WithServiceAccountFile(googleCredentials))
-		if err != nil {
-			log.Fatalln("Unable to create datastore client:", err)
-		}
-		defer dsClient.Close()
-
-		store := gerrit.NewGCPStore(dsClient)
-
-		g, err := gerrit.New(ctx, store, traceHTTPClient, logf, notify)
+		g, err := gerrit.New(ctx, gerritStore, traceHTTPClient, logf, notify)
 		if err != nil {
 			log.Fatalln("Unable to initialize gerrit poller:", err)
 		}
 
+		gerritCheck := checks.Register("gerrit", 2*time.Hour, false)
+		pollers.Add(1)
 		go func() {
-			ticker := time.NewTicker(30 * time.Minute)
-
-			g.Poll(ctx)
-			for range ticker.C {
-				g.Poll(ctx)
-			}
+			defer pollers.Done()
+			every(ctx, 30*time.Minute, true, func() {
+				err := g.Poll(workCtx)
+				if err != nil {
+					logf("polling Gerrit: %v", err)
+				}
+				gerritCheck.Report(err)
+			})
 		}()
 	} else {
 		logf("gerrit updates disabled in devMode")
 	}
 
+	// Configuration reloads
+	pollers.Add(1)
+	go func() {
+		defer pollers.Done()
+		every(ctx, configPollInterval, false, func() {
+			ctx, span := bot.StartSpan(workCtx, "Config.Reload")
+			defer span.End()
+
+			if _, err := reloader.Reload(ctx); err != nil {
+				bot.SpanError(span, err)
+				logf("reloading config: %v", err)
+			}
+		})
+	}()
+
 	// GoTime Livestream Notifications
 	{
 		notify := func() bool {
-			err = b.PostMessage(ctx, "gotimefm", ":tada: GoTimeFM is now live :tada:")
+			err := b.PostMessage(workCtx, "gotimefm", ":tada: GoTimeFM is now live :tada:")
 			if err != nil {
 				logf("error posting to #gotimefm: %v", err)
 				return false
@@ -364,53 +424,371 @@ func main() {
 		}
 
 		gt := gotime.New(traceHTTPClient, 30*time.Minute, notify)
+		gotimeCheck := checks.Register("gotime", 30*time.Minute, false)
+		pollers.Add(1)
 		go func() {
-			gotimefm := time.NewTicker(1 * time.Minute)
-			defer gotimefm.Stop()
-
-			for range gotimefm.C {
-				err := gt.Poll(ctx)
+			defer pollers.Done()
+			every(ctx, 1*time.Minute, false, func() {
+				err := gt.Poll(workCtx)
 				if err != nil {
 					logf("polling GoTime: %v", err)
 				}
-			}
+				gotimeCheck.Report(err)
+			})
 		}()
 	}
 
-	// healthz endpoint
+	// Prometheus metrics
+	mux.Handle("/metrics", promhttp.Handler())
+
+	// Health checks. Without a liveness probe, as on Heroku, the bot shuts
+	// down when it's stuck when exitWhenStuck is set, to be restarted.
+	stuck := make(chan struct{})
+	var stuckOnce sync.Once
+	pollers.Add(1)
 	go func() {
-		mux := http.NewServeMux()
-		mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		defer pollers.Done()
+		every(ctx, healthCheckInterval, true, func() {
+			slackCheck.Report(b.CheckAuth(workCtx))
+			workersCheck.Report(b.CheckWorkers())
+			_, err := gerritStore.LatestNumber(workCtx)
+			if err == gerrit.ErrNotFound {
+				err = nil
+			}
+			storageCheck.Report(err)
+
+			if !checks.Live() {
+				logf("Gopher is stuck: %+v", checks.Status())
+				if exitWhenStuck {
+					stuckOnce.Do(func() { close(stuck) })
+				}
+			}
+		})
+	}()
+
+	// healthz (liveness) and readyz (readiness) endpoints, served alongside
+	// any Slack endpoints registered on mux. Both describe every check.
+	healthHandler := func(name string, ok func(health.Status) bool) http.Handler {
+		return otelhttp.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if r.Method != "GET" {
 				http.NotFound(w, r)
 				return
 			}
 
-			span := traceClient.SpanFromRequest(r)
-			defer span.Finish()
-
+			status := checks.Status()
 			w.Header().Add("Content-Type", "application/json")
-			fmt.Fprintln(w, `{"version": "`+BotVersion+`"}`)
-		})
+			if !ok(status) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+			json.NewEncoder(w).Encode(struct {
+				Version string               `json:"version"`
+				Status  string               `json:"status"`
+				Events  bot.Stats            `json:"events"`
+				Checks  []health.CheckStatus `json:"checks"`
+			}{BotVersion, status.Status, b.Stats(), status.Checks})
+		}), name)
+	}
+	mux.Handle("/healthz", healthHandler("healthz", health.Status.Live))
+	mux.Handle("/readyz", healthHandler("readyz", health.Status.Ready))
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8081"
+	}
 
-		port := os.Getenv("PORT")
-		if port == "" {
-			port = "8081"
+	srv := &http.Server{
+		Addr:         ":" + port,
+		Handler:      mux,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	log.Println("Gopher is now running")
+	span.End()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case s := <-sig:
+		logf("received %s, shutting down", s)
+	case <-stuck:
+		logf("shutting down to be restarted")
+		exitCode = 1
+	}
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+
+	// The HTTP server is shut down before events stop being received, so
+	// the Events API requests it acknowledged are handled.
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logf("shutting down HTTP server: %v", err)
+	}
+	cancel()
+	if err := b.Shutdown(shutdownCtx); err != nil {
+		logf("waiting for handlers: %v", err)
+	}
+	if err := wait(shutdownCtx, &pollers); err != nil {
+		logf("waiting for pollers: %v", err)
+	}
+	if tracerProvider != nil {
+		if err := tracerProvider.Shutdown(shutdownCtx); err != nil {
+			logf("exporting spans: %v", err)
+		}
+	}
+	log.Println("Gopher has shut down")
+}
+
+// newTracerProvider creates a TracerProvider exporting spans in batches with
+// exporter: "otlp" sends them to the OTLP/HTTP collector configured by the
+// OTEL_EXPORTER_OTLP_* environment variables, "stdout" writes them as JSON.
+func newTracerProvider(ctx context.Context, exporter string) (*sdktrace.TracerProvider, error) {
+	var (
+		e   sdktrace.SpanExporter
+		err error
+	)
+	switch exporter {
+	case "otlp":
+		e, err = otlptracehttp.New(ctx)
+	case "stdout":
+		e, err = stdouttrace.New()
+	default:
+		err = fmt.Errorf("unknown exporter %q", exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults.
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceName("gopher"), semconv.ServiceVersion(BotVersion)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(e),
+		sdktrace.WithResource(res),
+	), nil
+}
+
+// envInt parses the value of the environment variable name, which defaults to
+// def when empty.
+func envInt(name, value string, def int) int {
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		log.Fatalf("%s must be a positive number, got %q", name, value)
+	}
+	return n
+}
+
+// splitList splits a comma separated list, such as "U1, U2", ignoring spaces
+// and empty entries.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
+	}
+	return items
+}
+
+// every calls f every d until ctx is done. f is also called right away when
+// now is true. A call in progress isn't interrupted when ctx is done.
+func every(ctx context.Context, d time.Duration, now bool, f func()) {
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
 
-		s := http.Server{
-			Addr:         ":" + port,
-			Handler:      mux,
-			ReadTimeout:  5 * time.Second,
-			WriteTimeout: 10 * time.Second,
+	if now {
+		f()
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			f()
 		}
+	}
+}
 
-		log.Fatal(s.ListenAndServe())
+// wait waits for wg, or until ctx is done.
+func wait(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
 	}()
 
-	log.Println("Gopher is now running")
-	span.Finish()
-	select {}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// newHandlers builds the message, team join and reaction handlers used by the
+// bot from cfg. moderators are the IDs of users allowed to dismiss any reply
+// and to reload the configuration with reloadConfig. interactive reports
+// whether interactions are received, so replies can have buttons.
+//
+// cfg is rejected when a prompt of its responses is also the name of another
+// command.
+func newHandlers(cfg *config.Config, httpClient *http.Client, transport bot.Transport, moderators []string, interactive bool, reloadConfig func(context.Context) (bool, error), logf bot.Logger) (bot.Handler, bot.JoinHandler, bot.ReactionHandler, error) {
+	welcomeChannels := channels(cfg.WelcomeChannels)
+	recommendedChannels := append(welcomeChannels[:len(welcomeChannels):len(welcomeChannels)], channels(cfg.RecommendedChannels)...)
+
+	joinHandler := handlers.Join(welcomeChannels)
+
+	comics := make([]string, 0, len(cfg.XKCD))
+	for name := range cfg.XKCD {
+		comics = append(comics, name)
+	}
+	sort.Strings(comics)
+
+	commands := []handlers.Command{
+		handlers.BotStack([]string{"stack", "where do you live?"}).Describe("About me", "where I live and what I'm made of"),
+		handlers.BotVersion("version", BotVersion).Describe("About me", "my version"),
+		handlers.CoinFlip([]string{"coin flip", "flip a coin"}).Describe("Fun", "flip a coin"),
+		handlers.RecommendedChannels("recommended channels", recommendedChannels).Describe("Community", "get a list of recommended channels"),
+		handlers.NewbieResources("newbie resources").Describe("Learning", "get a list of newbie resources, `pvt` sends them as a direct message"),
+		handlers.SearchForLibrary("library for").Describe("Search", "search a Go package that matches <name>"),
+		handlers.XKCD("xkcd", cfg.XKCD, logf).Describe("Fun", "link an XKCD comic by number or name: "+strings.Join(comics, ", ")),
+		handlers.ReloadConfig("reload config", moderators, reloadConfig).Describe("Moderation", "reload my configuration, for moderators").In(handlers.DirectMessageOnly),
+	}
+	builtins := len(commands)
+	for _, r := range cfg.Responses {
+		c := handlers.RespondTo(r.Prompts, string(r.Response)).Describe(r.Category, r.Description)
+		if r.Ephemeral {
+			c = handlers.EphemeralCommand(c)
+		}
+		commands = append(commands, c)
+	}
+	router := handlers.NewRouter(commands...)
+	if err := conflictsError(router.Conflicts(), commands, builtins); err != nil {
+		return nil, nil, nil, err
+	}
+
+	var triggers, directed []bot.Handler
+	for _, t := range cfg.Triggers {
+		if t.Directed {
+			directed = append(directed, newTrigger(t))
+		} else {
+			triggers = append(triggers, newTrigger(t))
+		}
+	}
+	directed = append(directed, router)
+
+	msgHandlers := bot.Chain(handlers.ProcessParallel(parallelHandlers, handlerTimeout,
+		instrument("handlers.Triggers", handlers.Triggers(triggers...)),
+
+		instrument("handlers.Songs", handlers.Songs()), // TODO: Is this used?
+		instrument("handlers.SuggestPlayground", handlers.SuggestPlayground(httpClient, transport, logf, 10, interactive)),
+		instrument("handlers.LinkToGoDoc.d", handlers.LinkToGoDoc("d/", "https://godoc.org/")),
+		instrument("handlers.LinkToGoDoc.ghd", handlers.LinkToGoDoc("ghd/", "https://godoc.org/github.com/")),
+
+		handlers.WhenDirectedToBot(instrument("handlers.Commands", handlers.Otherwise(
+			handlers.Triggers(directed...),
+			handlers.DidYouMean(router),
+		))),
+	), bot.Recover(logf))
+
+	reactionHandler := handlers.DismissReply(cfg.DismissReaction, moderators, logf)
+
+	return msgHandlers, joinHandler, reactionHandler, nil
+}
+
+// conflictsError describes conflicts between the commands of a router in the
+// style of config.Validate, or returns nil when there are none. The first
+// builtins commands are built into the bot, the others are the responses of
+// the configuration, followed by help.
+func conflictsError(conflicts []handlers.Conflict, commands []handlers.Command, builtins int) error {
+	response := func(i int) bool { return i >= builtins && i < len(commands) }
+	builtin := func(i int) string {
+		if i == len(commands) {
+			return "the built-in command \"help\""
+		}
+		return fmt.Sprintf("the built-in command %q", commands[i].Name)
+	}
+
+	var problems []string
+	for _, c := range conflicts {
+		switch {
+		case response(c.Shadowed) && response(c.Command):
+			problems = append(problems, fmt.Sprintf("responses[%d]: prompt %q is already used by responses[%d]", c.Shadowed-builtins, c.Name, c.Command-builtins))
+		case response(c.Shadowed):
+			problems = append(problems, fmt.Sprintf("responses[%d]: prompt %q is already used by %s", c.Shadowed-builtins, c.Name, builtin(c.Command)))
+		case response(c.Command):
+			problems = append(problems, fmt.Sprintf("responses[%d]: prompt %q is already used by %s", c.Command-builtins, c.Name, builtin(c.Shadowed)))
+		default:
+			problems = append(problems, fmt.Sprintf("%s and %s both use %q", builtin(c.Command), builtin(c.Shadowed), c.Name))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New("invalid config:\n\t" + strings.Join(problems, "\n\t"))
+	}
+	return nil
+}
+
+// instrument records a span and metrics named name for each message handled
+// by h, and cancels h after handlerTimeout.
+func instrument(name string, h bot.Handler) bot.Handler {
+	return bot.Chain(h, bot.Trace(name), bot.Measure(name), bot.Timeout(handlerTimeout))
+}
+
+func channels(cs []config.Channel) []handlers.Channel {
+	hs := make([]handlers.Channel, len(cs))
+	for i, c := range cs {
+		hs[i] = handlers.Channel{Name: c.Name, Description: c.Description}
+	}
+	return hs
+}
+
+// newTrigger builds the handler for a trigger of the configuration, which
+// has been validated.
+func newTrigger(t config.Trigger) bot.Handler {
+	if t.Prefix != "" {
+		return handlers.ReactWhenHasPrefix(t.Prefix, t.Reactions...)
+	}
+
+	var h handlers.Trigger
+	switch {
+	case t.Matches != "" && len(t.Reactions) > 0:
+		h = handlers.ReactWhenMatches(regexp.MustCompile(t.Matches), t.Reactions...)
+	case t.Matches != "":
+		h = handlers.RespondWhenMatches(regexp.MustCompile(t.Matches), t.Response)
+	case len(t.Reactions) > 0:
+		h = handlers.ReactWhenContains(t.Contains, t.Reactions...)
+	default:
+		h = handlers.RespondWhenContains(t.Contains, t.Response)
+	}
+
+	if t.WholeWord {
+		h = h.WholeWord()
+	}
+	if t.IgnoreCase {
+		h = h.IgnoreCase()
+	}
+	if t.Probability > 0 {
+		h = h.Probability(t.Probability)
+	}
+	if t.Cooldown > 0 {
+		h = h.Cooldown(time.Duration(t.Cooldown))
+	}
+	return h
 }
 
 // decode the base64 encoded google credential file data to a temporary file on the file system.
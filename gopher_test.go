@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gobridge/gopher/bot"
+	"github.com/gobridge/gopher/bot/bottest"
+	"github.com/gobridge/gopher/config"
+	"github.com/nlopes/slack"
+)
+
+func newTestBot(t *testing.T) *bottest.Transport {
+	t.Helper()
+	return newTestBotWithConfig(t, defaultConfigFile)
+}
+
+// newTestBotWithConfig creates a bot reloading its configuration from the file
+// at path when moderator UMOD asks for it.
+func newTestBotWithConfig(t *testing.T, path string) *bottest.Transport {
+	t.Helper()
+
+	transport := bottest.NewTransport("UGOPHER", "gopher")
+	var (
+		b        *bot.Bot
+		reloader *config.Reloader
+	)
+	build := func(cfg *config.Config) (bot.Handler, bot.JoinHandler, bot.ReactionHandler, error) {
+		return newHandlers(cfg, http.DefaultClient, transport, []string{"UMOD"}, false, reloader.Reload, t.Logf)
+	}
+	reloader = config.NewReloader(config.File(path), func(cfg *config.Config) error {
+		msgHandlers, joinHandler, reactionHandler, err := build(cfg)
+		if err != nil {
+			return err
+		}
+		b.SetHandlers(msgHandlers, joinHandler, reactionHandler)
+		return nil
+	})
+	cfg, err := reloader.Load(context.Background())
+	if err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+	msgHandlers, joinHandler, reactionHandler, err := build(cfg)
+	if err != nil {
+		t.Fatalf("building handlers: %v", err)
+	}
+
+	b = bot.New(transport, false, t.Logf, msgHandlers, joinHandler, reactionHandler)
+	if err := b.Init(context.Background()); err != nil {
+		t.Fatalf("init bot: %v", err)
+	}
+
+	return transport
+}
+
+func TestHandlers(t *testing.T) {
+	t.Run("responds to directed command", func(t *testing.T) {
+		transport := newTestBot(t)
+		transport.SendMessage("C1", "U1", "<@UGOPHER> newbie resources")
+
+		ok := transport.Wait(time.Second, func(tr *bottest.Transport) bool {
+			return len(tr.Messages()) == 1
+		})
+		if !ok {
+			t.Fatalf("expected 1 message, got %d", len(transport.Messages()))
+		}
+
+		msg := transport.Messages()[0]
+		if msg.Channel != "C1" {
+			t.Errorf("expected channel: %q\nactual: %q", "C1", msg.Channel)
+		}
+		blocks, err := json.Marshal(msg.Blocks)
+		if err != nil {
+			t.Fatalf("marshaling blocks: %v", err)
+		}
+		if !strings.Contains(string(blocks), "https://tour.golang.org/") {
+			t.Errorf("expected newbie resources blocks, got %s", blocks)
+		}
+	})
+
+	t.Run("ignores undirected command", func(t *testing.T) {
+		transport := newTestBot(t)
+		transport.SendMessage("C1", "U1", "newbie resources")
+
+		if transport.Wait(100*time.Millisecond, func(tr *bottest.Transport) bool {
+			return len(tr.Messages()) > 0
+		}) {
+			t.Errorf("expected no messages, got %#v", transport.Messages())
+		}
+	})
+
+	t.Run("reacts to keywords", func(t *testing.T) {
+		transport := newTestBot(t)
+		ts := transport.SendMessage("C1", "U1", "who's up for bbq")
+
+		ok := transport.Wait(time.Second, func(tr *bottest.Transport) bool {
+			return len(tr.Reactions()) == 1
+		})
+		if !ok {
+			t.Fatalf("expected 1 reaction, got %d", len(transport.Reactions()))
+		}
+
+		expected := bottest.Reaction{Name: "bbqgopher", Channel: "C1", Timestamp: ts}
+		if r := transport.Reactions()[0]; r != expected {
+			t.Errorf("expected: %#v\nactual: %#v", expected, r)
+		}
+	})
+
+	t.Run("responds to help ephemerally", func(t *testing.T) {
+		transport := newTestBot(t)
+		transport.SendMessage("C1", "U1", "<@UGOPHER> help")
+
+		ok := transport.Wait(time.Second, func(tr *bottest.Transport) bool {
+			return len(tr.Messages()) == 1
+		})
+		if !ok {
+			t.Fatalf("expected 1 message, got %d", len(transport.Messages()))
+		}
+
+		msg := transport.Messages()[0]
+		if msg.Channel != "C1" || msg.EphemeralUser != "U1" || !strings.HasPrefix(msg.Text, "Here's a list of supported commands") {
+			t.Errorf("unexpected help message: %#v", msg)
+		}
+	})
+
+	t.Run("responds to ephemeral response ephemerally", func(t *testing.T) {
+		transport := newTestBot(t)
+		transport.SendMessage("C1", "U1", "<@UGOPHER> source")
+
+		ok := transport.Wait(time.Second, func(tr *bottest.Transport) bool {
+			return len(tr.Messages()) == 1
+		})
+		if !ok {
+			t.Fatalf("expected 1 message, got %d", len(transport.Messages()))
+		}
+
+		msg := transport.Messages()[0]
+		if msg.EphemeralUser != "U1" || !strings.HasPrefix(msg.Text, "My source code is here") {
+			t.Errorf("unexpected response: %#v", msg)
+		}
+	})
+
+	t.Run("suggests near-miss command", func(t *testing.T) {
+		transport := newTestBot(t)
+		transport.SendMessage("C1", "U1", "<@UGOPHER> recomended channels")
+
+		ok := transport.Wait(time.Second, func(tr *bottest.Transport) bool {
+			return len(tr.Messages()) == 1
+		})
+		if !ok {
+			t.Fatalf("expected 1 message, got %d", len(transport.Messages()))
+		}
+
+		msg := transport.Messages()[0]
+		if msg.EphemeralUser != "U1" || !strings.HasPrefix(msg.Text, "Did you mean `recommended channels`") {
+			t.Errorf("unexpected suggestion: %#v", msg)
+		}
+	})
+
+	t.Run("falls back to direct message", func(t *testing.T) {
+		transport := newTestBot(t)
+		transport.EphemeralErr = errors.New("user_not_in_channel")
+		transport.SendMessage("C1", "U1", "<@UGOPHER> help")
+
+		ok := transport.Wait(time.Second, func(tr *bottest.Transport) bool {
+			return len(tr.Messages()) == 1
+		})
+		if !ok {
+			t.Fatalf("expected 1 message, got %d", len(transport.Messages()))
+		}
+
+		if msg := transport.Messages()[0]; msg.Channel != "U1" || msg.EphemeralUser != "" {
+			t.Errorf("expected direct message to U1, got %#v", msg)
+		}
+	})
+
+	t.Run("dismisses reply on reaction", func(t *testing.T) {
+		transport := newTestBot(t)
+		transport.SendMessage("C1", "U1", "<@UGOPHER> newbie resources")
+
+		ok := transport.Wait(time.Second, func(tr *bottest.Transport) bool {
+			return len(tr.Messages()) == 1
+		})
+		if !ok {
+			t.Fatalf("expected 1 message, got %d", len(transport.Messages()))
+		}
+		reply := transport.Messages()[0]
+
+		transport.SendReaction(reply.Channel, reply.Timestamp, "U2", "x")
+		if transport.Wait(100*time.Millisecond, func(tr *bottest.Transport) bool {
+			return tr.Messages()[0].Deleted
+		}) {
+			t.Fatalf("expected reply to be kept when dismissed by another user")
+		}
+
+		transport.SendReaction(reply.Channel, reply.Timestamp, "U1", "x")
+		ok = transport.Wait(time.Second, func(tr *bottest.Transport) bool {
+			return tr.Messages()[0].Deleted
+		})
+		if !ok {
+			t.Errorf("expected reply to be deleted, got %#v", transport.Messages()[0])
+		}
+	})
+
+	t.Run("welcomes new users", func(t *testing.T) {
+		transport := newTestBot(t)
+		transport.SendTeamJoin(slack.User{ID: "U2", Name: "newgopher"})
+
+		ok := transport.Wait(time.Second, func(tr *bottest.Transport) bool {
+			return len(tr.Messages()) == 1
+		})
+		if !ok {
+			t.Fatalf("expected 1 message, got %d", len(transport.Messages()))
+		}
+
+		msg := transport.Messages()[0]
+		if msg.Channel != "U2" || !strings.HasPrefix(msg.Text, "Hello newgopher,") {
+			t.Errorf("unexpected welcome message: %#v", msg)
+		}
+	})
+}
+
+func TestReloadConfig(t *testing.T) {
+	original, err := ioutil.ReadFile(defaultConfigFile)
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+	f, err := ioutil.TempFile("", "gopher-config-*.json")
+	if err != nil {
+		t.Fatalf("creating config: %v", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(original); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+	f.Close()
+
+	transport := newTestBotWithConfig(t, f.Name())
+	send := func(user, text string) string {
+		t.Helper()
+		n := len(transport.Messages())
+		transport.SendMessage("D1", user, text)
+		ok := transport.Wait(time.Second, func(tr *bottest.Transport) bool {
+			return len(tr.Messages()) == n+1
+		})
+		if !ok {
+			t.Fatalf("expected a response to %q", text)
+		}
+		return transport.Messages()[n].Text
+	}
+
+	updated := strings.Replace(string(original), "My source code is here", "My source code lives here", 1)
+	if err := ioutil.WriteFile(f.Name(), []byte(updated), 0644); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+
+	transport.SendMessage("C1", "UMOD", "<@UGOPHER> reload config")
+	ok := transport.Wait(time.Second, func(tr *bottest.Transport) bool {
+		return len(tr.Messages()) == 1
+	})
+	if !ok {
+		t.Fatalf("expected a response to reload config in a channel")
+	}
+	if msg := transport.Messages()[0]; msg.EphemeralUser != "UMOD" || !strings.Contains(msg.Text, "only works in a direct message") {
+		t.Errorf("expected reload in a channel to be refused, got %#v", msg)
+	}
+
+	if msg := send("U1", "reload config"); !strings.HasPrefix(msg, "Sorry, only moderators") {
+		t.Errorf("expected reload to be refused, got %q", msg)
+	}
+	if msg := send("U1", "source"); !strings.HasPrefix(msg, "My source code is here") {
+		t.Errorf("expected original response, got %q", msg)
+	}
+	if msg := send("UMOD", "reload config"); msg != "Reloaded my configuration." {
+		t.Errorf("expected reload, got %q", msg)
+	}
+	if msg := send("U1", "source"); !strings.HasPrefix(msg, "My source code lives here") {
+		t.Errorf("expected updated response, got %q", msg)
+	}
+
+	if err := ioutil.WriteFile(f.Name(), []byte(`{"responses": [{"prompts": ["source"]}]}`), 0644); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+	if msg := send("UMOD", "reload config"); !strings.Contains(msg, "responses[0]: response is required") {
+		t.Errorf("expected invalid config to be reported, got %q", msg)
+	}
+	if msg := send("U1", "source"); !strings.HasPrefix(msg, "My source code lives here") {
+		t.Errorf("expected last valid config to be kept, got %q", msg)
+	}
+
+	if err := ioutil.WriteFile(f.Name(), []byte(`{"responses": [{"prompts": ["source", "Help"], "response": "shadowing"}]}`), 0644); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+	if msg := send("UMOD", "reload config"); !strings.Contains(msg, `responses[0]: prompt "help" is already used by the built-in command "help"`) {
+		t.Errorf("expected conflicting prompt to be reported, got %q", msg)
+	}
+	if msg := send("U1", "help"); !strings.HasPrefix(msg, "Here's a list of supported commands") {
+		t.Errorf("expected help to be kept, got %q", msg)
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	tests := []struct {
+		list     string
+		expected []string
+	}{
+		{"", nil},
+		{"U1", []string{"U1"}},
+		{"U1,U2", []string{"U1", "U2"}},
+		{" U1, U2 ,,", []string{"U1", "U2"}},
+	}
+	for _, tt := range tests {
+		if actual := splitList(tt.list); !reflect.DeepEqual(tt.expected, actual) {
+			t.Errorf("%q: expected: %q\nactual: %q", tt.list, tt.expected, actual)
+		}
+	}
+}
@@ -8,9 +8,13 @@ import (
 	"net/http"
 	"time"
 
-	"cloud.google.com/go/trace"
+	"github.com/gobridge/gopher/bot"
+	"github.com/gobridge/gopher/metrics"
 )
 
+var polls = metrics.NewCounterVec("gopher_gotime_polls_total",
+	"Polls of the GoTime live stream status, by result.", "result")
+
 type GoTime struct {
 	http              *http.Client
 	notify            func() bool
@@ -40,9 +44,10 @@ func New(c *http.Client, startTimeVariance time.Duration, notify func() bool) *G
 // For a notification to be posted: a notification must not have been successful
 // in the last 24 hours, changelog is currently streaming, and there is
 // a GoTime episode scheduled within +/-startTimeVariance.
-func (gt *GoTime) Poll(ctx context.Context) error {
-	span := trace.FromContext(ctx).NewChild("GoTime.Poll")
-	defer span.Finish()
+func (gt *GoTime) Poll(ctx context.Context) (err error) {
+	ctx, span := bot.StartSpan(ctx, "GoTime.Poll")
+	defer span.End()
+	defer func() { polls.WithLabelValues(metrics.Result(err)).Inc() }()
 
 	now := time.Now()
 	if gt.lastNotified.After(now.Add(-24 * time.Hour)) {
@@ -52,7 +57,7 @@ func (gt *GoTime) Poll(ctx context.Context) error {
 	var status struct {
 		Streaming bool
 	}
-	err := gt.get(ctx, span, "https://changelog.com/live/status", &status)
+	err = gt.get(ctx, "https://changelog.com/live/status", &status)
 	if err != nil {
 		return err
 	}
@@ -64,7 +69,7 @@ func (gt *GoTime) Poll(ctx context.Context) error {
 	var countdown struct {
 		Data time.Time
 	}
-	err = gt.get(ctx, span, "https://changelog.com/slack/countdown/gotime", &countdown)
+	err = gt.get(ctx, "https://changelog.com/slack/countdown/gotime", &countdown)
 	if err != nil {
 		return err
 	}
@@ -82,9 +87,9 @@ func (gt *GoTime) Poll(ctx context.Context) error {
 }
 
 // get makes an HTTP request to url and unmarshals the JSON response into i.
-func (gt *GoTime) get(ctx context.Context, span *trace.Span, url string, i interface{}) error {
-	childSpan := span.NewChild("GoTime.get")
-	defer childSpan.Finish()
+func (gt *GoTime) get(ctx context.Context, url string, i interface{}) error {
+	ctx, span := bot.StartSpan(ctx, "GoTime.get")
+	defer span.End()
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/gobridge/gopher/bot"
+)
+
+// ReloadConfig is a command named name letting moderators reload the
+// configuration of the bot with reload, which reports whether the
+// configuration changed.
+func ReloadConfig(name string, moderators []string, reload func(context.Context) (bool, error)) Command {
+	mods := userSet(moderators)
+	return EphemeralCommand(Command{
+		Name: name,
+		Run: func(ctx context.Context, m bot.Message, args Args, r bot.Responder) {
+			if !mods[m.Event.User] {
+				r.Respond(ctx, "Sorry, only moderators can reload my configuration.")
+				return
+			}
+
+			changed, err := reload(ctx)
+			switch {
+			case err != nil:
+				r.Respond(ctx, "I kept my current configuration, the new one can't be loaded:\n```"+err.Error()+"```")
+			case changed:
+				r.Respond(ctx, "Reloaded my configuration.")
+			default:
+				r.Respond(ctx, "My configuration didn't change.")
+			}
+		},
+	})
+}
+
+func userSet(ids []string) map[string]bool {
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
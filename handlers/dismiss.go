@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/gobridge/gopher/bot"
+)
+
+// DismissReply deletes a bot reply when the author of the message it responds
+// to, or one of the moderators, reacts to it with reaction.
+func DismissReply(reaction string, moderators []string, logf bot.Logger) bot.ReactionHandler {
+	mods := userSet(moderators)
+
+	return bot.ReactionHandlerFunc(func(ctx context.Context, r bot.Reaction) {
+		if r.Reply == nil || r.Event.Reaction != reaction {
+			return
+		}
+		if r.Event.User != r.Author && !mods[r.Event.User] {
+			return
+		}
+
+		if err := r.Reply.Delete(ctx); err != nil {
+			logf("dismissing reply %s in %s: %v\n", r.Reply.Timestamp(), r.Reply.Channel(), err)
+			return
+		}
+		logf("%s dismissed reply %s in %s\n", r.Event.User, r.Reply.Timestamp(), r.Reply.Channel())
+	})
+}
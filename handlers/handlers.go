@@ -9,8 +9,11 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gobridge/gopher/bot"
+	"github.com/nlopes/slack"
 )
 
 // Channel describes a Slack channel.
@@ -19,52 +22,149 @@ type Channel struct {
 	Description string
 }
 
-// ProcessLinear calls handlers in order.
+// ProcessLinear calls handlers in order. It consumes the message if any of
+// the handlers did.
 func ProcessLinear(hs ...bot.Handler) bot.Handler {
-	return bot.HandlerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) {
+	return bot.ConsumerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) bool {
+		var consumed bool
 		for _, h := range hs {
-			h.Handle(ctx, m, r)
+			if bot.Consume(ctx, h, m, r) {
+				consumed = true
+			}
 		}
+		return consumed
 	})
 }
 
-// RespondWhenContains responds to any message when that contains s.
-func RespondWhenContains(s string, response string) bot.Handler {
-	return bot.HandlerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) {
-		if strings.Contains(m.Event.Text, s) {
-			r.Respond(ctx, response)
+// ProcessFirst calls handlers in order until one of them consumes the
+// message, see bot.Consume.
+func ProcessFirst(hs ...bot.Handler) bot.Handler {
+	return bot.ConsumerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) bool {
+		for _, h := range hs {
+			if bot.Consume(ctx, h, m, r) {
+				return true
+			}
 		}
+		return false
 	})
 }
 
-// WhenDirectedToBot calls h when Message.DirectedToBot is true.
-func WhenDirectedToBot(h bot.Handler) bot.Handler {
-	return bot.HandlerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) {
-		if m.DirectedToBot {
-			h.Handle(ctx, m, r)
+// ProcessParallel calls handlers concurrently, at most limit at a time, so a
+// slow handler doesn't delay the others. The context passed to the handlers
+// is canceled after timeout. It waits for all handlers to return and consumes
+// the message if any of them did.
+//
+// The timeout is cooperative: handlers must return once the context is done,
+// a handler which doesn't, such as one blocked on a call ignoring the
+// context, keeps ProcessParallel waiting past it.
+//
+// A panic in a handler is raised again once all handlers returned, so it can
+// be recovered by middleware such as bot.Recover.
+func ProcessParallel(limit int, timeout time.Duration, hs ...bot.Handler) bot.Handler {
+	if limit <= 0 || limit > len(hs) {
+		limit = len(hs)
+	}
+	return bot.ConsumerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) bool {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		var (
+			wg       sync.WaitGroup
+			mu       sync.Mutex
+			consumed bool
+			panicked interface{}
+		)
+		sem := make(chan struct{}, limit)
+		for _, h := range hs {
+			h := h
+			sem <- struct{}{}
+			wg.Add(1)
+			go func() {
+				defer func() {
+					if v := recover(); v != nil {
+						mu.Lock()
+						if panicked == nil {
+							panicked = v
+						}
+						mu.Unlock()
+					}
+					<-sem
+					wg.Done()
+				}()
+
+				if bot.Consume(ctx, h, m, r) {
+					mu.Lock()
+					consumed = true
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		if panicked != nil {
+			panic(panicked)
 		}
+		return consumed
 	})
 }
 
-// RespondTo responds to messages when Message.TrimmedText is in prompts.
-func RespondTo(prompts []string, response string) bot.Handler {
-	return bot.HandlerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) {
-		for _, prompt := range prompts {
-			if m.TrimmedText == prompt {
-				r.Respond(ctx, response)
-				return
-			}
-		}
+// ephemeralResponder sends responses only visible to the user who sent the
+// message.
+type ephemeralResponder struct {
+	bot.Responder
+}
+
+func (r ephemeralResponder) Respond(ctx context.Context, msg string) *bot.Reply {
+	return r.RespondEphemeral(ctx, msg)
+}
+
+func (r ephemeralResponder) RespondUnfurled(ctx context.Context, msg string) *bot.Reply {
+	return r.RespondEphemeral(ctx, msg)
+}
+
+func (r ephemeralResponder) RespondWithAttachment(ctx context.Context, msg, attachment string) *bot.Reply {
+	return r.RespondEphemeral(ctx, msg, bot.NewBlocks().Section(msg).Section(attachment).Blocks()...)
+}
+
+func (r ephemeralResponder) RespondWithBlocks(ctx context.Context, msg string, blocks ...slack.Block) *bot.Reply {
+	return r.RespondEphemeral(ctx, msg, blocks...)
+}
+
+// RespondWhenContains responds to any message when that contains s.
+func RespondWhenContains(s string, response string) Trigger {
+	return Trigger{
+		Substring: s,
+		Handler: bot.HandlerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) {
+			r.Respond(ctx, response)
+		}),
+	}
+}
+
+// WhenDirectedToBot calls h when Message.DirectedToBot is true.
+func WhenDirectedToBot(h bot.Handler) bot.Handler {
+	return bot.ConsumerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) bool {
+		return m.DirectedToBot && bot.Consume(ctx, h, m, r)
 	})
 }
 
+// RespondTo is a command named by prompts which responds with response.
+func RespondTo(prompts []string, response string) Command {
+	return Command{
+		Name:    prompts[0],
+		Aliases: prompts[1:],
+		Run: func(ctx context.Context, m bot.Message, args Args, r bot.Responder) {
+			r.Respond(ctx, response)
+		},
+	}
+}
+
 // ReactWhenContains adds reactions to messages that contain s.
-func ReactWhenContains(s string, reactions ...string) bot.Handler {
-	return bot.HandlerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) {
-		if !strings.Contains(m.Event.Text, s) {
-			return
-		}
+func ReactWhenContains(s string, reactions ...string) Trigger {
+	return Trigger{Substring: s, Handler: react(reactions)}
+}
 
+func react(reactions []string) bot.Handler {
+	return bot.HandlerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) {
 		for _, reaction := range reactions {
 			r.React(ctx, reaction)
 		}
@@ -84,23 +184,27 @@ func ReactWhenHasPrefix(s string, reactions ...string) bot.Handler {
 	})
 }
 
-// ReactWhenContainsRand randomly calls ReactWhenContains.
-//
-// Currently probability is 1/150.
-func ReactWhenContainsRand(s string, reactions ...string) bot.Handler {
-	h := ReactWhenContains(s, reactions...)
-	return bot.HandlerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) {
-		// TODO: make probability configurable?
-		if !(rand.Intn(150) == 0x2A) {
-			return
-		}
-		h.Handle(ctx, m, r)
-	})
+// ReactWhenContainsRand calls ReactWhenContains with the given probability,
+// between 0 and 1.
+func ReactWhenContainsRand(s string, probability float64, reactions ...string) Trigger {
+	return ReactWhenContains(s, reactions...).Probability(probability)
 }
 
-// BotStack responds to messages with information about the bot and where
-// it runs when Message.TrimmedText contains one of prompts.
-func BotStack(prompts []string) bot.Handler {
+// RespondWhenMatches responds to messages that match re with response.
+func RespondWhenMatches(re *regexp.Regexp, response string) Trigger {
+	t := RespondWhenContains("", response)
+	t.pattern = re
+	return t
+}
+
+// ReactWhenMatches adds reactions to messages that match re.
+func ReactWhenMatches(re *regexp.Regexp, reactions ...string) Trigger {
+	return Trigger{Handler: react(reactions), pattern: re}
+}
+
+// BotStack is a command named by prompts which responds with information about
+// the bot and where it runs.
+func BotStack(prompts []string) Command {
 	var msg string
 	dyno := os.Getenv("DYNO")
 	switch {
@@ -111,115 +215,102 @@ func BotStack(prompts []string) bot.Handler {
 	}
 	msg += "\nYou can find my source code at: <https://github.com/gobridge/gopher>."
 
-	return bot.HandlerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) {
-		for _, prompt := range prompts {
-			if m.TrimmedText == prompt {
-				r.Respond(ctx, msg)
-				return
-			}
-		}
-	})
+	return RespondTo(prompts, msg)
 }
 
-// BotVersion responds to messages with the bot's version when Message.TrimmedText
-// matches prompt.
-func BotVersion(prompt, version string) bot.Handler {
-	msg := "My version is: " + version
-	return bot.HandlerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) {
-		if m.TrimmedText != prompt {
-			return
-		}
-		r.Respond(ctx, msg)
-	})
+// BotVersion is a command named prompt which responds with the bot's version.
+func BotVersion(prompt, version string) Command {
+	return RespondTo([]string{prompt}, "My version is: "+version)
 }
 
-// CoinFlip responds to messages with "heads" or "tails" when Message.TrimmedText
-// matches one of prompts.
-func CoinFlip(prompts []string) bot.Handler {
-	return bot.HandlerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) {
-		for _, prompt := range prompts {
-			if m.TrimmedText == prompt {
-				if rand.Intn(2) == 0 {
-					r.Respond(ctx, "heads")
-				} else {
-					r.Respond(ctx, "tails")
-				}
-				return
+// CoinFlip is a command named by prompts which responds with "heads" or
+// "tails".
+func CoinFlip(prompts []string) Command {
+	return Command{
+		Name:    prompts[0],
+		Aliases: prompts[1:],
+		Run: func(ctx context.Context, m bot.Message, args Args, r bot.Responder) {
+			if rand.Intn(2) == 0 {
+				r.Respond(ctx, "heads")
+			} else {
+				r.Respond(ctx, "tails")
 			}
-		}
-	})
+		},
+	}
 }
 
-// RecommendedChannels responds to messages a formatted list of channels when
-// Message.TrimmedText matches prompt.
-func RecommendedChannels(prompt string, channels []Channel) bot.Handler {
-	var recommendedChannels string
-	for _, c := range channels {
-		recommendedChannels += fmt.Sprintf("- #%s -> %s\n", c.Name, c.Description)
-	}
+// RecommendedChannels is a command named prompt which responds with a
+// formatted list of channels.
+func RecommendedChannels(prompt string, channels []Channel) Command {
+	const msg = "Here is a list of recommended channels:"
 
-	return bot.HandlerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) {
-		if m.TrimmedText != prompt {
-			return
-		}
+	fields := make([]string, len(channels))
+	for i, c := range channels {
+		fields[i] = fmt.Sprintf("*#%s*\n%s", c.Name, c.Description)
+	}
+	blocks := bot.NewBlocks().
+		Section(msg).
+		Fields(fields...).
+		Blocks()
 
-		r.RespondWithAttachment(ctx, "Here is a list of recommended channels:", recommendedChannels)
-	})
+	return Command{
+		Name: prompt,
+		Run: func(ctx context.Context, m bot.Message, args Args, r bot.Responder) {
+			r.RespondWithBlocks(ctx, msg, blocks...)
+		},
+	}
 }
 
-// SearchForLibrary responds to messages with suggested places to look for
-// a library when Message.TrimmedText has prefix.
-func SearchForLibrary(prefix string) bot.Handler {
+// SearchForLibrary is a command named prefix which responds with suggested
+// places to look for a library.
+func SearchForLibrary(prefix string) Command {
 	var (
 		emojiRE     = regexp.MustCompile(`:[[:alnum:]]+:`)
 		slackLinkRE = regexp.MustCompile(`<((?:@u)|(?:#c))[0-9a-z]+>`)
 	)
 
-	return bot.HandlerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) {
-		if !strings.HasPrefix(m.TrimmedText, prefix) {
-			return
-		}
-
-		searchTerm := strings.TrimPrefix(m.TrimmedText, prefix)
-		searchTerm = slackLinkRE.ReplaceAllString(searchTerm, "")
-		searchTerm = emojiRE.ReplaceAllString(searchTerm, "")
-		searchTerm = strings.Trim(searchTerm, "?;., ")
-		if len(searchTerm) == 0 || len(searchTerm) > 100 {
-			return
-		}
+	return Command{
+		Name: prefix,
+		Args: []Arg{{Name: "name", Type: Text}},
+		Run: func(ctx context.Context, m bot.Message, args Args, r bot.Responder) {
+			searchTerm := slackLinkRE.ReplaceAllString(args["name"], "")
+			searchTerm = emojiRE.ReplaceAllString(searchTerm, "")
+			searchTerm = strings.Trim(searchTerm, "?;., ")
+			if len(searchTerm) == 0 || len(searchTerm) > 100 {
+				return
+			}
 
-		searchTerm = url.QueryEscape(searchTerm)
-		r.Respond(ctx, `You can try to look here: <https://godoc.org/?q=`+searchTerm+`> or here <http://go-search.org/search?q=`+searchTerm+`>`)
-	})
+			searchTerm = url.QueryEscape(searchTerm)
+			r.Respond(ctx, `You can try to look here: <https://godoc.org/?q=`+searchTerm+`> or here <http://go-search.org/search?q=`+searchTerm+`>`)
+		},
+	}
 }
 
-// XKCD responds with XKCD comics when Message.TrimmedText has prefix.
+// XKCD is a command named prefix which responds with XKCD comics.
 //
 // After the prefix either a comic number or alias can be provided.
-func XKCD(prefix string, aliases map[string]int, logf bot.Logger) bot.Handler {
-	return bot.HandlerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) {
-		if !strings.HasPrefix(m.TrimmedText, prefix) {
-			return
-		}
-
-		eventText := strings.TrimPrefix(m.TrimmedText, prefix)
-
-		// first check known aliases for certain comics
-		// otherwise parse the number out of the evet text
-		comicID, ok := aliases[eventText]
-		if !ok {
-			// Verify it's an integer to be nice to XKCD
-			num, err := strconv.Atoi(eventText)
-			if err != nil {
-				// pretend we didn't hear them if they give bad data
-				logf("Error while attempting to parse XKCD string: %v\n", err)
-				return
+func XKCD(prefix string, aliases map[string]int, logf bot.Logger) Command {
+	return Command{
+		Name: prefix,
+		Args: []Arg{{Name: "comic", Type: Word}},
+		Run: func(ctx context.Context, m bot.Message, args Args, r bot.Responder) {
+			// first check known aliases for certain comics
+			// otherwise parse the number out of the evet text
+			comicID, ok := aliases[args["comic"]]
+			if !ok {
+				// Verify it's an integer to be nice to XKCD
+				num, err := strconv.Atoi(args["comic"])
+				if err != nil {
+					// pretend we didn't hear them if they give bad data
+					logf("Error while attempting to parse XKCD string: %v\n", err)
+					return
+				}
+				comicID = num
 			}
-			comicID = num
-		}
 
-		r.RespondUnfurled(ctx, fmt.Sprintf("<https://xkcd.com/%d/>", comicID))
-	})
+			r.RespondUnfurled(ctx, fmt.Sprintf("<https://xkcd.com/%d/>", comicID))
+		},
+	}
 }
 
 // LinkToGoDoc responds with to messages with matchPrefix replaced by urlPrefix.
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gobridge/gopher/bot"
+	"github.com/nlopes/slack"
+)
+
+func TestProcess(t *testing.T) {
+	m := bot.Message{Event: &slack.MessageEvent{Msg: slack.Msg{Text: "hello"}}, TrimmedText: "hello"}
+
+	var mu sync.Mutex
+	var calls []string
+	record := func(name string, consume bool) bot.Handler {
+		return bot.ConsumerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) bool {
+			mu.Lock()
+			calls = append(calls, name)
+			mu.Unlock()
+			return consume
+		})
+	}
+	responds := bot.HandlerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) {
+		mu.Lock()
+		calls = append(calls, "responds")
+		mu.Unlock()
+		r.Respond(ctx, "hi")
+	})
+
+	tests := []struct {
+		name     string
+		h        bot.Handler
+		consumed bool
+		expected []string
+	}{
+		{"linear", ProcessLinear(record("a", false), record("b", true), record("c", false)), true, []string{"a", "b", "c"}},
+		{"linear unconsumed", ProcessLinear(record("a", false), record("b", false)), false, []string{"a", "b"}},
+		{"first", ProcessFirst(record("a", false), record("b", true), record("c", true)), true, []string{"a", "b"}},
+		{"first responding", ProcessFirst(record("a", false), responds, record("c", true)), true, []string{"a", "responds"}},
+		{"first unconsumed", ProcessFirst(record("a", false), record("b", false)), false, []string{"a", "b"}},
+		{"parallel", ProcessParallel(1, time.Second, record("a", false), record("b", true)), true, []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = nil
+			rr := recordingResponder{}
+			if consumed := bot.Consume(context.Background(), tt.h, m, &rr); consumed != tt.consumed {
+				t.Errorf("expected consumed: %t\nactual: %t", tt.consumed, consumed)
+			}
+			if !reflect.DeepEqual(tt.expected, calls) {
+				t.Errorf("expected: %v\nactual: %v", tt.expected, calls)
+			}
+		})
+	}
+
+	t.Run("parallel limits concurrency", func(t *testing.T) {
+		var running, max int32
+		h := bot.HandlerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				old := atomic.LoadInt32(&max)
+				if n <= old || atomic.CompareAndSwapInt32(&max, old, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		})
+
+		ProcessParallel(2, time.Second, h, h, h, h, h).Handle(context.Background(), m, nil)
+
+		if max != 2 {
+			t.Errorf("expected: 2 concurrent handlers\nactual: %d", max)
+		}
+	})
+
+	t.Run("parallel cancels handlers after timeout", func(t *testing.T) {
+		var err error
+		h := bot.HandlerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) {
+			<-ctx.Done()
+			err = ctx.Err()
+		})
+
+		ProcessParallel(0, time.Millisecond, h).Handle(context.Background(), m, nil)
+
+		if err != context.DeadlineExceeded {
+			t.Errorf("expected: %v\nactual: %v", context.DeadlineExceeded, err)
+		}
+	})
+
+	t.Run("parallel raises panics", func(t *testing.T) {
+		h := bot.HandlerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) {
+			panic("boom")
+		})
+
+		defer func() {
+			if v := recover(); v != "boom" {
+				t.Errorf("expected: boom\nactual: %v", v)
+			}
+		}()
+		ProcessParallel(0, time.Second, h).Handle(context.Background(), m, nil)
+	})
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/gobridge/gopher/bot"
+)
+
+// RouteInteractions calls the handler registered in routes for the action ID
+// of block actions, or the callback ID of shortcuts and view submissions.
+func RouteInteractions(routes map[string]bot.InteractionHandler) bot.InteractionHandler {
+	return bot.InteractionHandlerFunc(func(ctx context.Context, i bot.Interaction, r bot.InteractionResponder) {
+		var id string
+		switch i.Type {
+		case bot.InteractionBlockActions:
+			if len(i.Actions) > 0 {
+				id = i.Actions[0].ActionID
+			}
+		case bot.InteractionViewSubmission:
+			if i.View != nil {
+				id = i.View.CallbackID
+			}
+		default:
+			id = i.CallbackID
+		}
+
+		if h, ok := routes[id]; ok {
+			h.Handle(ctx, i, r)
+		}
+	})
+}
+
+// DismissAction is the action ID of DismissButton, DismissMessage must be
+// routed to it.
+const DismissAction = "dismiss"
+
+// DismissButton is a button deleting the bot message it is attached to when
+// clicked by author, the user the message responds to, or a moderator.
+func DismissButton(author string) bot.Button {
+	return bot.Button{ActionID: DismissAction, Text: "Dismiss", Value: author}
+}
+
+// DismissMessage deletes the message the interaction originated from when the
+// user clicking DismissButton is its author or one of the moderators.
+func DismissMessage(moderators []string, logf bot.Logger) bot.InteractionHandler {
+	mods := userSet(moderators)
+
+	return bot.InteractionHandlerFunc(func(ctx context.Context, i bot.Interaction, r bot.InteractionResponder) {
+		if len(i.Actions) == 0 {
+			return
+		}
+		if i.User.ID != i.Actions[0].Value && !mods[i.User.ID] {
+			r.Respond(ctx, "Sorry, only the person I replied to or a moderator can dismiss this message.")
+			return
+		}
+
+		r.DeleteMessage(ctx)
+		logf("%s dismissed message in %s\n", i.User.ID, i.Channel.ID)
+	})
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/gobridge/gopher/bot"
+	"github.com/nlopes/slack"
+)
+
+type recordingInteractionResponder struct {
+	bot.InteractionResponder
+	msgs    []string
+	deleted bool
+}
+
+func (rr *recordingInteractionResponder) Respond(ctx context.Context, msg string) {
+	rr.msgs = append(rr.msgs, msg)
+}
+
+func (rr *recordingInteractionResponder) DeleteMessage(ctx context.Context) {
+	rr.deleted = true
+}
+
+func blockAction(user, actionID, value string) bot.Interaction {
+	i := bot.Interaction{
+		Type:    bot.InteractionBlockActions,
+		Actions: []*slack.BlockAction{{ActionID: actionID, Value: value}},
+	}
+	i.User.ID = user
+	return i
+}
+
+func TestRouteInteractions(t *testing.T) {
+	var routed []string
+	route := func(name string) bot.InteractionHandler {
+		return bot.InteractionHandlerFunc(func(ctx context.Context, i bot.Interaction, r bot.InteractionResponder) {
+			routed = append(routed, name)
+		})
+	}
+	rt := RouteInteractions(map[string]bot.InteractionHandler{
+		"helpful":  route("helpful"),
+		"report":   route("report"),
+		"feedback": route("feedback"),
+	})
+
+	submission := bot.Interaction{Type: bot.InteractionViewSubmission, View: &bot.View{CallbackID: "feedback"}}
+	shortcut := bot.Interaction{Type: bot.InteractionMessageAction, CallbackID: "report"}
+
+	tests := []struct {
+		name     string
+		i        bot.Interaction
+		expected []string
+	}{
+		{"block action", blockAction("U1", "helpful", ""), []string{"helpful"}},
+		{"view submission", submission, []string{"feedback"}},
+		{"shortcut", shortcut, []string{"report"}},
+		{"unknown action", blockAction("U1", "unknown", ""), nil},
+		{"no actions", bot.Interaction{Type: bot.InteractionBlockActions}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routed = nil
+			rt.Handle(context.Background(), tt.i, &recordingInteractionResponder{})
+			if !reflect.DeepEqual(tt.expected, routed) {
+				t.Errorf("expected: %v\nactual: %v", tt.expected, routed)
+			}
+		})
+	}
+}
+
+func TestDismissMessage(t *testing.T) {
+	h := DismissMessage([]string{"UMOD"}, t.Logf)
+
+	tests := []struct {
+		name    string
+		user    string
+		deleted bool
+	}{
+		{"author", "UAUTHOR", true},
+		{"moderator", "UMOD", true},
+		{"someone else", "UOTHER", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			button := DismissButton("UAUTHOR")
+			var rr recordingInteractionResponder
+			h.Handle(context.Background(), blockAction(tt.user, button.ActionID, button.Value), &rr)
+
+			if rr.deleted != tt.deleted {
+				t.Errorf("expected deleted: %t\nactual: %t", tt.deleted, rr.deleted)
+			}
+			if !tt.deleted && len(rr.msgs) != 1 {
+				t.Errorf("expected a refusal, got %v", rr.msgs)
+			}
+		})
+	}
+}
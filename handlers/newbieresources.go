@@ -7,25 +7,32 @@ import (
 	"github.com/gobridge/gopher/bot"
 )
 
-// NewbieResources responds with some beginner resources when Message.TrimmedText
-// has prefix.
+// NewbieResources is a command named prefix which responds with some beginner
+// resources.
 //
-// If Message.TrimmedText also contains "pvt" the response will be sent as a direct
+// If the command is followed by "pvt" the response will be sent as a direct
 // message.
-func NewbieResources(prefix string) bot.Handler {
-	return bot.HandlerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) {
-		if !strings.HasPrefix(m.TrimmedText, prefix) {
-			return
-		}
-
-		msg := "Here are some resources you should check out if you are learning / new to Go:"
-		if strings.Contains(m.TrimmedText, "pvt") { // TODO: Is this useful? Direct messaging the bot works as well.
-			r.RespondPrivateWithAttachment(ctx, msg, newbieResources)
-			return
-		}
-
-		r.RespondWithAttachment(ctx, msg, newbieResources)
-	})
+func NewbieResources(prefix string) Command {
+	const msg = "Here are some resources you should check out if you are learning / new to Go:"
+
+	bb := bot.NewBlocks().Section("*" + msg + "*").Divider()
+	for _, paragraph := range strings.Split(newbieResources, "\n\n") {
+		bb.Section(paragraph)
+	}
+	blocks := bb.Blocks()
+
+	return Command{
+		Name: prefix,
+		Args: []Arg{{Name: "pvt", Type: Word, Optional: true}},
+		Run: func(ctx context.Context, m bot.Message, args Args, r bot.Responder) {
+			if args["pvt"] == "pvt" { // TODO: Is this useful? Direct messaging the bot works as well.
+				r.RespondPrivateWithBlocks(ctx, msg, blocks...)
+				return
+			}
+
+			r.RespondWithBlocks(ctx, msg, blocks...)
+		},
+	}
 }
 
 var newbieResources = `First you should take the language tour: <https://tour.golang.org/>
@@ -11,27 +11,37 @@ import (
 	"time"
 
 	"github.com/gobridge/gopher/bot"
+	"github.com/gobridge/gopher/metrics"
 	"github.com/nlopes/slack"
 )
 
+var playgroundUploads = metrics.NewCounterVec("gopher_playground_uploads_total",
+	"Code uploaded to the Go playground, by result.", "result")
+
+// fileDownloadTimeout is how long downloading an uploaded file may take.
+const fileDownloadTimeout = 10 * time.Second
+
 type playground struct {
-	http     *http.Client
-	slack    *slack.Client
-	logf     bot.Logger
-	minLines int
+	http        *http.Client
+	transport   bot.Transport
+	logf        bot.Logger
+	minLines    int
+	dismissible bool
 }
 
 // SuggestPlayground uploads messages/files to the playground when they have at least minLines or
 // has files that are of type "go" or "text".
 //
 // After uploading, a link will be posted to the channel and a suggestion to use the playground is
-// sent directly to the user.
-func SuggestPlayground(h *http.Client, s *slack.Client, l bot.Logger, minLines int) bot.Handler {
+// shown only to the user. When dismissible is true, the link has a DismissButton, which requires
+// DismissMessage to handle interactions.
+func SuggestPlayground(h *http.Client, t bot.Transport, l bot.Logger, minLines int, dismissible bool) bot.Handler {
 	return playground{
-		http:     h,
-		slack:    s,
-		logf:     l,
-		minLines: minLines,
+		http:        h,
+		transport:   t,
+		logf:        l,
+		minLines:    minLines,
+		dismissible: dismissible,
 	}
 }
 
@@ -60,10 +70,14 @@ func (p playground) suggestPlaygroundUploads(ctx context.Context, m bot.Message,
 
 	// Empirically, attempting to call GetFileInfoContext too quickly after a
 	// file is uploaded can cause a "file_not_found" error.
-	time.Sleep(1 * time.Second)
+	select {
+	case <-time.After(1 * time.Second):
+	case <-ctx.Done():
+		return
+	}
 
 	for _, file := range m.Event.Files {
-		info, _, _, err := p.slack.GetFileInfoContext(ctx, file.ID, 0, 0)
+		info, err := p.transport.GetFileInfo(ctx, file.ID)
 		if err != nil {
 			p.logf("error while getting file info for ID %q: %v", file.ID, err)
 			return
@@ -73,23 +87,27 @@ func (p playground) suggestPlaygroundUploads(ctx context.Context, m bot.Message,
 			return
 		}
 
+		reply := r.Respond(ctx, playgroundWorking)
+
 		var buf bytes.Buffer
-		err = p.slack.GetFile(info.URLPrivateDownload, &buf)
+		err = p.download(ctx, info.URLPrivateDownload, &buf)
 		if err != nil {
 			p.logf("error while fetching the file %v\n", err)
+			p.cancel(ctx, reply)
 			return
 		}
 
 		link, err := p.postToPlayground(ctx, &buf)
 		if err != nil {
 			p.logf("%s\n", err)
+			p.cancel(ctx, reply)
 			return
 		}
 
-		r.Respond(ctx, `The above code in playground: <`+link+`>`)
+		p.respondWithLink(ctx, m, r, reply, link)
 	}
 
-	r.RespondPrivate(ctx,
+	r.RespondEphemeral(ctx,
 		`Hello. I've noticed you uploaded a Go file. To facilitate collaboration and make `+
 			`this easier for others to share back the snippet, please consider using: `+
 			`<https://play.golang.org>. If you wish to not link against the playground, please use `+
@@ -98,21 +116,62 @@ func (p playground) suggestPlaygroundUploads(ctx context.Context, m bot.Message,
 }
 
 func (p playground) suggestPlaygroundPost(ctx context.Context, m bot.Message, r bot.Responder) {
+	reply := r.Respond(ctx, playgroundWorking)
+
 	link, err := p.postToPlayground(ctx, strings.NewReader(m.Event.Text))
 	if err != nil {
 		p.logf("%s\n", err)
+		p.cancel(ctx, reply)
 		return
 	}
 
-	r.Respond(ctx, `The above code in playground: <`+string(link)+`>`)
-	r.RespondPrivate(ctx, `Hello. I've noticed you've written a large block of text (more than 9 lines). `+
+	p.respondWithLink(ctx, m, r, reply, link)
+	r.RespondEphemeral(ctx, `Hello. I've noticed you've written a large block of text (more than 9 lines). `+
 		`To make the conversation easier to follow the conversation and facilitate collaboration, `+
 		`please consider using: <https://play.golang.org> if you shared code. If you wish to not `+
 		`link against the playground, please start the message with "nolink". Thank you.`,
 	)
 }
 
+// playgroundWorking is shown while the code is uploaded to the playground.
+const playgroundWorking = "Sharing the above code in playground…"
+
+// respondWithLink replaces the working reply with link, or responds with it
+// when there is no reply to replace.
+func (p playground) respondWithLink(ctx context.Context, m bot.Message, r bot.Responder, reply *bot.Reply, link string) {
+	msg := `The above code in playground: <` + link + `>`
+	var blocks []slack.Block
+	if p.dismissible {
+		blocks = bot.NewBlocks().Section(msg).Buttons(DismissButton(m.Event.User)).Blocks()
+	}
+
+	if err := reply.Update(ctx, msg, blocks...); err != nil {
+		if err != bot.ErrNoReply {
+			p.logf("updating playground reply: %v\n", err)
+		}
+		r.RespondWithBlocks(ctx, msg, blocks...)
+	}
+}
+
+// cancel deletes the working reply after uploading failed.
+func (p playground) cancel(ctx context.Context, reply *bot.Reply) {
+	if err := reply.Delete(ctx); err != nil && err != bot.ErrNoReply {
+		p.logf("deleting playground reply: %v\n", err)
+	}
+}
+
+// download downloads the uploaded file at url into w. It gives up after
+// fileDownloadTimeout, since the worker handling the message also handles
+// the other messages of its channels.
+func (p playground) download(ctx context.Context, url string, w io.Writer) error {
+	ctx, cancel := context.WithTimeout(ctx, fileDownloadTimeout)
+	defer cancel()
+	return p.transport.GetFile(ctx, url, w)
+}
+
 func (p playground) postToPlayground(ctx context.Context, body io.Reader) (link string, err error) {
+	defer func() { playgroundUploads.WithLabelValues(metrics.Result(err)).Inc() }()
+
 	req, err := http.NewRequest("POST", "https://play.golang.org/share", body)
 	if err != nil {
 		return "", err
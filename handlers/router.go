@@ -0,0 +1,375 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gobridge/gopher/bot"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ArgType is the type of a command argument.
+type ArgType int
+
+// Argument types.
+const (
+	Word   ArgType = iota // a single word
+	Number                // an integer
+	Text                  // the rest of the message, must be the last argument
+)
+
+// Arg is an argument of a Command.
+type Arg struct {
+	Name     string
+	Type     ArgType
+	Optional bool
+}
+
+// Args are the arguments passed to a command, keyed by Arg.Name. Missing
+// optional arguments are empty.
+type Args map[string]string
+
+// Int returns the Number argument name, or 0 if it's missing.
+func (a Args) Int(name string) int {
+	n, _ := strconv.Atoi(a[name])
+	return n
+}
+
+// CommandFunc runs a command with its parsed arguments.
+type CommandFunc func(ctx context.Context, m bot.Message, args Args, r bot.Responder)
+
+// Scope restricts where a command can be used.
+type Scope int
+
+// Command scopes.
+const (
+	Anywhere          Scope = iota
+	DirectMessageOnly       // only in direct messages with the bot
+	ChannelOnly             // only in channels
+)
+
+// Command is a command directed to the bot, such as "xkcd 927".
+type Command struct {
+	Name    string   // words the message must start with
+	Aliases []string // alternative names
+	Args    []Arg
+	Run     CommandFunc
+
+	// Category and Description document the command in help.
+	Category    string
+	Description string
+	Scope       Scope
+}
+
+// Describe returns c with category and description for help.
+func (c Command) Describe(category, description string) Command {
+	c.Category = category
+	c.Description = description
+	return c
+}
+
+// In returns c restricted to scope.
+func (c Command) In(scope Scope) Command {
+	c.Scope = scope
+	return c
+}
+
+// Usage describes how to use the command, such as "xkcd <comic>".
+func (c Command) Usage() string {
+	usage := c.Name
+	for _, a := range c.Args {
+		if a.Optional {
+			usage += " [" + a.Name + "]"
+		} else {
+			usage += " <" + a.Name + ">"
+		}
+	}
+	return usage
+}
+
+// parseArgs parses the arguments from tokens. text is the message tokens
+// were taken from, for Text arguments.
+func (c Command) parseArgs(text string, tokens []token) (Args, bool) {
+	args := make(Args, len(c.Args))
+	for _, a := range c.Args {
+		if a.Type == Text {
+			var rest string
+			if len(tokens) > 0 {
+				rest = strings.TrimSpace(text[tokens[0].start:])
+			}
+			if rest == "" && !a.Optional {
+				return nil, false
+			}
+			args[a.Name] = rest
+			return args, true
+		}
+
+		if len(tokens) == 0 {
+			if !a.Optional {
+				return nil, false
+			}
+			continue
+		}
+
+		t := tokens[0].text
+		if a.Type == Number {
+			if _, err := strconv.Atoi(t); err != nil {
+				return nil, false
+			}
+		}
+		args[a.Name] = t
+		tokens = tokens[1:]
+	}
+
+	// Anything after the arguments, such as "please", is ignored.
+	return args, true
+}
+
+// EphemeralCommand runs c with responses only visible to the user who sent
+// the message.
+func EphemeralCommand(c Command) Command {
+	run := c.Run
+	c.Run = func(ctx context.Context, m bot.Message, args Args, r bot.Responder) {
+		run(ctx, m, args, ephemeralResponder{r})
+	}
+	return c
+}
+
+// Router is a Handler running the Command whose name or alias matches the
+// most words at the start of Message.TrimmedText. Words are separated by
+// spaces or colons and surrounding punctuation is ignored, so "xkcd:927",
+// "xkcd 927" and "xkcd 927?" all run the "xkcd" command.
+//
+// Malformed arguments are answered with the usage of the command.
+//
+// The Router is also the registry of commands: it provides a "help" command
+// listing the commands by category, and "help <command>" describing one.
+type Router struct {
+	commands  []Command
+	routes    []route
+	index     map[string]int // command by space separated words of routes
+	maxWords  int            // most words of any route
+	conflicts []Conflict
+}
+
+type route struct {
+	words   []string
+	command int
+}
+
+// Conflict is a name or alias used by two commands. Commands are identified
+// by their index in the commands passed to NewRouter, the help command being
+// the last one.
+type Conflict struct {
+	Name     string // the name as matched, lowercase words separated by spaces
+	Command  int    // the command registered first, which runs
+	Shadowed int    // the command which never runs for Name
+}
+
+// NewRouter creates a Router for commands. When names overlap, the command
+// registered first wins, see Conflicts.
+func NewRouter(commands ...Command) *Router {
+	rt := &Router{index: make(map[string]int)}
+	rt.commands = append(commands[:len(commands):len(commands)], EphemeralCommand(Command{
+		Name:        "help",
+		Args:        []Arg{{Name: "command", Type: Text, Optional: true}},
+		Run:         rt.help,
+		Category:    "About me",
+		Description: "list the supported commands, or describe one",
+	}))
+	for i, c := range rt.commands {
+		for _, name := range append([]string{c.Name}, c.Aliases...) {
+			var words []string
+			for _, t := range tokenize(strings.ToLower(name)) {
+				words = append(words, t.text)
+			}
+			rt.routes = append(rt.routes, route{words: words, command: i})
+
+			key := strings.Join(words, " ")
+			switch j, ok := rt.index[key]; {
+			case !ok:
+				rt.index[key] = i
+			case j != i:
+				rt.conflicts = append(rt.conflicts, Conflict{Name: key, Command: j, Shadowed: i})
+			}
+			if len(words) > rt.maxWords {
+				rt.maxWords = len(words)
+			}
+		}
+	}
+	return rt
+}
+
+// Conflicts returns the names used by several commands, in the order of the
+// commands.
+func (rt *Router) Conflicts() []Conflict {
+	return rt.conflicts
+}
+
+// Handle implements bot.Handler.
+func (rt *Router) Handle(ctx context.Context, m bot.Message, r bot.Responder) {
+	rt.route(ctx, m, r)
+}
+
+// Consume implements bot.Consumer, a message is consumed when it matches a
+// command.
+func (rt *Router) Consume(ctx context.Context, m bot.Message, r bot.Responder) bool {
+	return rt.route(ctx, m, r)
+}
+
+// route runs the command matching m and reports whether there was one.
+func (rt *Router) route(ctx context.Context, m bot.Message, r bot.Responder) bool {
+	tokens := tokenize(m.TrimmedText)
+
+	best, bestLen, ok := rt.match(tokens)
+	if !ok {
+		return false
+	}
+
+	c := rt.commands[best]
+	ctx, span := bot.StartSpan(ctx, "Router.Command", attribute.String("command", c.Name))
+	defer span.End()
+
+	direct := strings.HasPrefix(m.Event.Channel, "D")
+	switch {
+	case c.Scope == DirectMessageOnly && !direct:
+		r.RespondEphemeral(ctx, "`"+c.Name+"` only works in a direct message with me.")
+		return true
+	case c.Scope == ChannelOnly && direct:
+		r.RespondEphemeral(ctx, "`"+c.Name+"` only works in channels.")
+		return true
+	}
+
+	args, ok := c.parseArgs(m.TrimmedText, tokens[bestLen:])
+	if !ok {
+		r.RespondEphemeral(ctx, "Usage: `"+c.Usage()+"`")
+		return true
+	}
+	c.Run(ctx, m, args, r)
+	return true
+}
+
+// match returns the command whose name or alias matches the most tokens, and
+// how many tokens it matches.
+func (rt *Router) match(tokens []token) (command, n int, ok bool) {
+	n = len(tokens)
+	if n > rt.maxWords {
+		n = rt.maxWords
+	}
+	for ; n > 0; n-- {
+		if c, ok := rt.index[joinTokens(tokens[:n])]; ok {
+			return c, n, true
+		}
+	}
+	return 0, 0, false
+}
+
+// lookup returns the command named by text.
+func (rt *Router) lookup(text string) (Command, bool) {
+	c, ok := rt.index[joinTokens(tokenize(strings.ToLower(text)))]
+	if !ok {
+		return Command{}, false
+	}
+	return rt.commands[c], true
+}
+
+// help responds with the commands grouped by category, or with the details of
+// the command in args.
+func (rt *Router) help(ctx context.Context, m bot.Message, args Args, r bot.Responder) {
+	if name := args["command"]; name != "" {
+		c, ok := rt.lookup(name)
+		if !ok {
+			r.Respond(ctx, "I don't know the command `"+name+"`, say `help` for a list of supported commands.")
+			return
+		}
+		r.Respond(ctx, describe(c))
+		return
+	}
+
+	var categories []string
+	byCategory := make(map[string][]string)
+	for _, c := range rt.commands {
+		if _, ok := byCategory[c.Category]; !ok {
+			categories = append(categories, c.Category)
+		}
+		byCategory[c.Category] = append(byCategory[c.Category], summarize(c))
+	}
+
+	lines := []string{"Here's a list of supported commands, say `help <command>` for details:"}
+	for _, category := range categories {
+		title := category
+		if title == "" {
+			title = "Other"
+		}
+		lines = append(lines, "", "*"+title+"*")
+		lines = append(lines, byCategory[category]...)
+	}
+	r.Respond(ctx, strings.Join(lines, "\n"))
+}
+
+// summarize describes c in a line.
+func summarize(c Command) string {
+	line := "- `" + c.Usage() + "`"
+	if c.Description != "" {
+		line += " -> " + c.Description
+	}
+	return line + scopeNote(c.Scope)
+}
+
+// describe describes c in detail.
+func describe(c Command) string {
+	lines := []string{summarize(c)}
+	if len(c.Aliases) > 0 {
+		lines = append(lines, "Also known as: `"+strings.Join(c.Aliases, "`, `")+"`")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func scopeNote(s Scope) string {
+	switch s {
+	case DirectMessageOnly:
+		return " (direct messages only)"
+	case ChannelOnly:
+		return " (channels only)"
+	}
+	return ""
+}
+
+// token is a word in a message, starting at byte offset start.
+type token struct {
+	text  string
+	start int
+}
+
+// tokenize splits s into words separated by spaces or colons, with
+// surrounding punctuation removed.
+func tokenize(s string) []token {
+	var tokens []token
+	start := -1
+	for i, c := range s + " " {
+		if unicode.IsSpace(c) || c == ':' {
+			if start >= 0 {
+				if t := strings.Trim(s[start:i], "?!.,;"); t != "" {
+					tokens = append(tokens, token{text: t, start: start})
+				}
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	return tokens
+}
+
+// joinTokens joins the text of tokens with spaces.
+func joinTokens(tokens []token) string {
+	words := make([]string, len(tokens))
+	for i, t := range tokens {
+		words[i] = t.text
+	}
+	return strings.Join(words, " ")
+}
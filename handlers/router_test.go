@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gobridge/gopher/bot"
+	"github.com/nlopes/slack"
+)
+
+type recordingResponder struct {
+	bot.Responder
+	msgs []string
+}
+
+func (rr *recordingResponder) Respond(ctx context.Context, msg string) *bot.Reply {
+	rr.msgs = append(rr.msgs, msg)
+	return nil
+}
+
+func (rr *recordingResponder) RespondEphemeral(ctx context.Context, msg string, blocks ...slack.Block) *bot.Reply {
+	rr.msgs = append(rr.msgs, msg)
+	return nil
+}
+
+func TestRouter(t *testing.T) {
+	echo := func(name string) CommandFunc {
+		return func(ctx context.Context, m bot.Message, args Args, r bot.Responder) {
+			r.Respond(ctx, name+" "+args["arg"])
+		}
+	}
+	rt := NewRouter(
+		Command{Name: "recommended", Aliases: []string{"recommended blogs"}, Run: echo("blogs")},
+		Command{Name: "recommended channels", Run: echo("channels")},
+		Command{Name: "xkcd", Args: []Arg{{Name: "arg", Type: Number}}, Run: echo("xkcd")},
+		Command{Name: "library for", Args: []Arg{{Name: "arg", Type: Text}}, Run: echo("library")},
+	)
+
+	route := func(text string) (bool, []string) {
+		m := bot.Message{
+			Event:         &slack.MessageEvent{Msg: slack.Msg{Text: text}},
+			TrimmedText:   text,
+			DirectedToBot: true,
+		}
+		var rr recordingResponder
+		ok := rt.route(context.Background(), m, &rr)
+		return ok, rr.msgs
+	}
+
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{"recommended", "blogs "},
+		{"recommended blogs", "blogs "},
+		{"recommended  channels please", "channels "},
+		{"recommended channels?", "channels "},
+		{"xkcd:927", "xkcd 927"},
+		{"xkcd 927", "xkcd 927"},
+		{"xkcd standards", "Usage: `xkcd <arg>`"},
+		{"xkcd", "Usage: `xkcd <arg>`"},
+		{"library for  web frameworks?", "library web frameworks?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			ok, msgs := route(tt.text)
+			if !ok || len(msgs) != 1 || msgs[0] != tt.expected {
+				t.Errorf("expected: %q\nactual: %t %q", tt.expected, ok, msgs)
+			}
+		})
+	}
+
+	t.Run("lists commands in help", func(t *testing.T) {
+		_, msgs := route("help")
+		expected := strings.Join([]string{
+			"Here's a list of supported commands, say `help <command>` for details:",
+			"",
+			"*Other*",
+			"- `recommended`",
+			"- `recommended channels`",
+			"- `xkcd <arg>`",
+			"- `library for <arg>`",
+			"",
+			"*About me*",
+			"- `help [command]` -> list the supported commands, or describe one",
+		}, "\n")
+		if len(msgs) != 1 || msgs[0] != expected {
+			t.Errorf("expected: %q\nactual: %q", expected, msgs)
+		}
+	})
+
+	t.Run("describes command in help", func(t *testing.T) {
+		_, msgs := route("help recommended blogs")
+		expected := "- `recommended`\nAlso known as: `recommended blogs`"
+		if len(msgs) != 1 || msgs[0] != expected {
+			t.Errorf("expected: %q\nactual: %q", expected, msgs)
+		}
+	})
+
+	t.Run("ignores unknown commands", func(t *testing.T) {
+		if ok, msgs := route("recommend me something"); ok || len(msgs) > 0 {
+			t.Errorf("expected no response, got %t %q", ok, msgs)
+		}
+	})
+}
+
+func TestRouterConflicts(t *testing.T) {
+	rt := NewRouter(
+		Command{Name: "xkcd", Aliases: []string{"XKCD"}},
+		Command{Name: "recommended channels", Aliases: []string{"channels"}},
+		Command{Name: "Channels"},
+		Command{Name: "help"},
+	)
+
+	expected := []Conflict{
+		{Name: "channels", Command: 1, Shadowed: 2},
+		{Name: "help", Command: 3, Shadowed: 4},
+	}
+	if actual := rt.Conflicts(); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected: %+v\nactual: %+v", expected, actual)
+	}
+}
+
+func TestRouterScope(t *testing.T) {
+	run := func(ctx context.Context, m bot.Message, args Args, r bot.Responder) {
+		r.Respond(ctx, "ok")
+	}
+	rt := NewRouter(
+		Command{Name: "secret", Run: run}.Describe("Private", "only in DMs").In(DirectMessageOnly),
+		Command{Name: "shout", Run: run}.In(ChannelOnly),
+	)
+
+	tests := []struct {
+		channel  string
+		text     string
+		expected string
+	}{
+		{"D1", "secret", "ok"},
+		{"C1", "secret", "`secret` only works in a direct message with me."},
+		{"C1", "shout", "ok"},
+		{"D1", "shout", "`shout` only works in channels."},
+		{"D1", "help secret", "- `secret` -> only in DMs (direct messages only)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.channel+" "+tt.text, func(t *testing.T) {
+			m := bot.Message{
+				Event:         &slack.MessageEvent{Msg: slack.Msg{Channel: tt.channel, Text: tt.text}},
+				TrimmedText:   tt.text,
+				DirectedToBot: true,
+			}
+			var rr recordingResponder
+			rt.Handle(context.Background(), m, &rr)
+			if len(rr.msgs) != 1 || rr.msgs[0] != tt.expected {
+				t.Errorf("expected: %q\nactual: %q", tt.expected, rr.msgs)
+			}
+		})
+	}
+}
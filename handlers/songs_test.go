@@ -13,8 +13,9 @@ type testResponder struct {
 	msg string
 }
 
-func (tr *testResponder) Respond(ctx context.Context, msg string) {
+func (tr *testResponder) Respond(ctx context.Context, msg string) *bot.Reply {
 	tr.msg = msg
+	return nil
 }
 
 func TestSongLink(t *testing.T) {
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/gobridge/gopher/bot"
+)
+
+// maxSuggestions is the maximum number of commands suggested by DidYouMean.
+const maxSuggestions = 3
+
+// Otherwise calls h, then fallback if h didn't consume the message, see
+// bot.Consume.
+func Otherwise(h, fallback bot.Handler) bot.Handler {
+	return bot.ConsumerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) bool {
+		if bot.Consume(ctx, h, m, r) {
+			return true
+		}
+		return bot.Consume(ctx, fallback, m, r)
+	})
+}
+
+// DidYouMean responds with the commands of rt closest to the message, such as
+// "recommended channels" for "recomended channels", and a pointer to help.
+// Messages which aren't close to any command are ignored.
+func DidYouMean(rt *Router) bot.Handler {
+	return bot.HandlerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) {
+		suggestions := rt.suggest(m.TrimmedText)
+		if len(suggestions) == 0 {
+			return
+		}
+
+		for i, s := range suggestions {
+			suggestions[i] = "`" + s + "`"
+		}
+		msg := "Did you mean " + suggestions[0]
+		if n := len(suggestions); n > 1 {
+			msg = "Did you mean " + strings.Join(suggestions[:n-1], ", ") + " or " + suggestions[n-1]
+		}
+		r.RespondEphemeral(ctx, msg+"? Say `help` for a list of supported commands.")
+	})
+}
+
+// suggest returns the names or aliases of the commands closest to text, best
+// first.
+func (rt *Router) suggest(text string) []string {
+	var words []string
+	for _, t := range tokenize(strings.ToLower(text)) {
+		words = append(words, t.text)
+	}
+	if len(words) == 0 {
+		return nil
+	}
+
+	type suggestion struct {
+		name     string
+		distance int
+	}
+	best := make(map[int]suggestion)
+	var order []int
+	for _, route := range rt.routes {
+		d, ok := closeness(words, route.words)
+		if !ok {
+			continue
+		}
+		s, seen := best[route.command]
+		if !seen {
+			order = append(order, route.command)
+		}
+		if !seen || d < s.distance {
+			best[route.command] = suggestion{name: strings.Join(route.words, " "), distance: d}
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return best[order[i]].distance < best[order[j]].distance
+	})
+	if len(order) > maxSuggestions {
+		order = order[:maxSuggestions]
+	}
+	names := make([]string, len(order))
+	for i, c := range order {
+		names[i] = best[c].name
+	}
+	return names
+}
+
+// closeness returns the distance between the words of a message and the words
+// of a command, and whether they are close enough to suggest the command.
+//
+// The start of the message is compared to the command with the edit distance,
+// which catches typos such as "recomended channels". Otherwise every word of
+// the command must be close to a word anywhere in the message, which catches
+// different word orders and extra words, such as "channels recommended" or
+// "newbie resource please".
+func closeness(message, command []string) (int, bool) {
+	n := len(command)
+	if n > len(message) {
+		n = len(message)
+	}
+	name := strings.Join(command, " ")
+	if d := editDistance(strings.Join(message[:n], " "), name); d <= tolerance(name) {
+		return d, true
+	}
+
+	// Reordered words count as one more edit.
+	total := 1
+	for _, cw := range command {
+		best := -1
+		for _, mw := range message {
+			if d := editDistance(mw, cw); d <= tolerance(cw) && (best < 0 || d < best) {
+				best = d
+			}
+		}
+		if best < 0 {
+			return 0, false
+		}
+		total += best
+	}
+	return total, total <= tolerance(name)
+}
+
+// tolerance is the edit distance allowed for s to still be considered a typo:
+// roughly one edit per four characters.
+func tolerance(s string) int {
+	return len([]rune(s)) / 4
+}
+
+// editDistance returns the Damerau-Levenshtein distance between a and b, so
+// swapping two adjacent letters counts as a single edit.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min3(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] && d[i-2][j-2]+1 < d[i][j] {
+				d[i][j] = d[i-2][j-2] + 1
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gobridge/gopher/bot"
+	"github.com/nlopes/slack"
+)
+
+func TestDidYouMean(t *testing.T) {
+	run := func(ctx context.Context, m bot.Message, args Args, r bot.Responder) {
+		r.Respond(ctx, "ok")
+	}
+	rt := NewRouter(
+		Command{Name: "recommended channels", Run: run},
+		Command{Name: "newbie resources", Args: []Arg{{Name: "pvt", Type: Word, Optional: true}}, Run: run},
+		Command{Name: "xkcd", Args: []Arg{{Name: "comic", Type: Word}}, Run: run},
+	)
+	h := Otherwise(ProcessLinear(ReactWhenHasPrefix("wave", "wave"), rt), DidYouMean(rt))
+
+	tests := []struct {
+		text     string
+		expected []string
+	}{
+		{"recommended channels", []string{"ok"}},
+		{"recomended channels", []string{"Did you mean `recommended channels`? Say `help` for a list of supported commands."}},
+		{"newbie resource pvt", []string{"Did you mean `newbie resources`? Say `help` for a list of supported commands."}},
+		{"channels recommended", []string{"Did you mean `recommended channels`? Say `help` for a list of supported commands."}},
+		{"xkdc 927", []string{"Did you mean `xkcd`? Say `help` for a list of supported commands."}},
+		{"halp", []string{"Did you mean `help`? Say `help` for a list of supported commands."}},
+		{"wave", nil},
+		{"what a lovely day", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			m := bot.Message{
+				Event:         &slack.MessageEvent{Msg: slack.Msg{Text: tt.text}},
+				TrimmedText:   tt.text,
+				DirectedToBot: true,
+			}
+			rr := recordingResponder{Responder: nopResponder{}}
+			h.Handle(context.Background(), m, &rr)
+			if len(rr.msgs) != len(tt.expected) || (len(rr.msgs) > 0 && rr.msgs[0] != tt.expected[0]) {
+				t.Errorf("expected: %q\nactual: %q", tt.expected, rr.msgs)
+			}
+		})
+	}
+}
+
+type nopResponder struct {
+	bot.Responder
+}
+
+func (nopResponder) React(ctx context.Context, reaction string) {}
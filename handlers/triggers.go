@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"context"
+	"math/rand"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gobridge/gopher/bot"
+)
+
+// Trigger is a Handler calling Handler for messages which contain Substring.
+// By default Substring is matched anywhere, even inside a word, and is case
+// sensitive: use WholeWord and IgnoreCase to change that.
+//
+// Triggers passed to Triggers are matched together, so adding more of them
+// doesn't make handling a message slower.
+type Trigger struct {
+	Substring string
+	Handler   bot.Handler
+
+	wholeWord   bool
+	ignoreCase  bool
+	pattern     *regexp.Regexp // matched instead of Substring if set
+	probability float64        // 0 means always
+	cooldown    *cooldown
+}
+
+// WholeWord returns t only matching Substring when it isn't part of a longer
+// word, so "dragon" doesn't match "dragonfly".
+func (t Trigger) WholeWord() Trigger {
+	t.wholeWord = true
+	return t
+}
+
+// IgnoreCase returns t matching Substring regardless of the case of ASCII
+// letters, so "bbq" matches "BBQ".
+func (t Trigger) IgnoreCase() Trigger {
+	t.ignoreCase = true
+	return t
+}
+
+// Probability returns t only calling Handler with probability p, between 0
+// and 1, for matching messages.
+func (t Trigger) Probability(p float64) Trigger {
+	t.probability = p
+	return t
+}
+
+// Cooldown returns t calling Handler at most once per d in each channel.
+func (t Trigger) Cooldown(d time.Duration) Trigger {
+	t.cooldown = newCooldown(d)
+	return t
+}
+
+// Handle implements bot.Handler.
+func (t Trigger) Handle(ctx context.Context, m bot.Message, r bot.Responder) {
+	if t.matches(m.Event.Text) {
+		t.fire(ctx, m, r)
+	}
+}
+
+// matches reports whether text matches t.
+func (t Trigger) matches(text string) bool {
+	if t.pattern != nil {
+		return t.pattern.MatchString(text)
+	}
+
+	substr := t.Substring
+	if t.ignoreCase {
+		text, substr = foldASCII(text), foldASCII(substr)
+	}
+	for offset := 0; offset <= len(text); {
+		i := strings.Index(text[offset:], substr)
+		if i < 0 {
+			return false
+		}
+		end := offset + i + len(substr)
+		if t.at(text, end) {
+			return true
+		}
+		offset += i + 1
+	}
+	return false
+}
+
+// at reports whether an occurrence of Substring ending at byte offset end of
+// text is a match.
+func (t Trigger) at(text string, end int) bool {
+	if !t.wholeWord {
+		return true
+	}
+	before, _ := utf8.DecodeLastRuneInString(text[:end-len(t.Substring)])
+	after, _ := utf8.DecodeRuneInString(text[end:])
+	return !isWordRune(before) && !isWordRune(after)
+}
+
+// fire calls Handler for a matching message, unless it's left out by the
+// probability or cooldown of t.
+func (t Trigger) fire(ctx context.Context, m bot.Message, r bot.Responder) {
+	if t.probability > 0 && rand.Float64() >= t.probability {
+		return
+	}
+	if t.cooldown != nil && !t.cooldown.allow(m.Event.Channel) {
+		return
+	}
+	t.Handler.Handle(ctx, m, r)
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// foldASCII returns s with ASCII letters in lower case. Unlike
+// strings.ToLower it keeps byte offsets unchanged.
+func foldASCII(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		b[i] = foldByte(c)
+	}
+	return string(b)
+}
+
+func foldByte(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// cooldown limits how often something happens in each channel.
+type cooldown struct {
+	d   time.Duration
+	now func() time.Time
+
+	mu   sync.Mutex
+	last map[string]time.Time // by channel
+}
+
+func newCooldown(d time.Duration) *cooldown {
+	return &cooldown{d: d, now: time.Now, last: make(map[string]time.Time)}
+}
+
+// allow reports whether the cooldown in channel is over, and starts a new one
+// if so.
+func (c *cooldown) allow(channel string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if last, ok := c.last[channel]; ok && now.Sub(last) < c.d {
+		return false
+	}
+	c.last[channel] = now
+	return true
+}
+
+// Triggers calls handlers in order like ProcessLinear, except that the
+// substrings of all the Trigger handlers are looked for in a single scan of the
+// message, and only the matching ones are called. Triggers with a regular
+// expression are matched one by one.
+func Triggers(hs ...bot.Handler) bot.Handler {
+	var exact, folded []string
+	var exactIDs, foldedIDs []int
+	var triggers []Trigger
+	for _, h := range hs {
+		t, ok := h.(Trigger)
+		if !ok {
+			continue
+		}
+		switch {
+		case t.pattern != nil:
+		case t.ignoreCase:
+			folded = append(folded, foldASCII(t.Substring))
+			foldedIDs = append(foldedIDs, len(triggers))
+		default:
+			exact = append(exact, t.Substring)
+			exactIDs = append(exactIDs, len(triggers))
+		}
+		triggers = append(triggers, t)
+	}
+	exactMatcher := newMatcher(exact, false)
+	foldedMatcher := newMatcher(folded, true)
+
+	return bot.ConsumerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) bool {
+		text := m.Event.Text
+		matched := make([]bool, len(triggers))
+		for i, t := range triggers {
+			if t.pattern != nil {
+				matched[i] = t.pattern.MatchString(text)
+			}
+		}
+		exactMatcher.match(text, func(p, end int) {
+			id := exactIDs[p]
+			matched[id] = matched[id] || triggers[id].at(text, end)
+		})
+		foldedMatcher.match(text, func(p, end int) {
+			id := foldedIDs[p]
+			matched[id] = matched[id] || triggers[id].at(text, end)
+		})
+
+		var consumed bool
+		var i int
+		for _, h := range hs {
+			if t, ok := h.(Trigger); ok {
+				h = nil
+				if matched[i] {
+					h = bot.HandlerFunc(t.fire)
+				}
+				i++
+			}
+			if h != nil && bot.Consume(ctx, h, m, r) {
+				consumed = true
+			}
+		}
+		return consumed
+	})
+}
+
+// matcher finds the occurrences of a set of patterns in a text in a single
+// pass, using the Aho-Corasick algorithm.
+type matcher struct {
+	fold  bool // match ASCII letters regardless of case
+	nodes []matcherNode
+}
+
+type matcherNode struct {
+	next map[byte]int
+	fail int
+	out  []int // patterns ending at this node
+}
+
+// newMatcher creates a matcher for patterns. If fold is true, patterns must
+// be in lower case, see foldASCII.
+func newMatcher(patterns []string, fold bool) *matcher {
+	mt := &matcher{fold: fold, nodes: []matcherNode{{next: map[byte]int{}}}}
+
+	// Build the trie of the patterns.
+	for i, p := range patterns {
+		n := 0
+		for j := 0; j < len(p); j++ {
+			next, ok := mt.nodes[n].next[p[j]]
+			if !ok {
+				next = len(mt.nodes)
+				mt.nodes = append(mt.nodes, matcherNode{next: map[byte]int{}})
+				mt.nodes[n].next[p[j]] = next
+			}
+			n = next
+		}
+		mt.nodes[n].out = append(mt.nodes[n].out, i)
+	}
+
+	// Link every node to the node of its longest proper suffix in the trie,
+	// breadth first so suffixes are linked before the nodes using them.
+	queue := make([]int, 0, len(mt.nodes))
+	for _, next := range mt.nodes[0].next {
+		queue = append(queue, next)
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for c, next := range mt.nodes[n].next {
+			fail := mt.nodes[n].fail
+			for fail > 0 && !mt.has(fail, c) {
+				fail = mt.nodes[fail].fail
+			}
+			if f, ok := mt.nodes[fail].next[c]; ok && f != next {
+				fail = f
+			}
+			mt.nodes[next].fail = fail
+			mt.nodes[next].out = append(mt.nodes[next].out, mt.nodes[fail].out...)
+			queue = append(queue, next)
+		}
+	}
+	return mt
+}
+
+func (mt *matcher) has(n int, c byte) bool {
+	_, ok := mt.nodes[n].next[c]
+	return ok
+}
+
+// match calls f with the index of the pattern and the byte offset in text
+// where it ends for every occurrence of a pattern in text.
+func (mt *matcher) match(text string, f func(pattern, end int)) {
+	for _, p := range mt.nodes[0].out {
+		f(p, 0) // empty patterns
+	}
+
+	n := 0
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if mt.fold {
+			c = foldByte(c)
+		}
+		for n > 0 && !mt.has(n, c) {
+			n = mt.nodes[n].fail
+		}
+		if next, ok := mt.nodes[n].next[c]; ok {
+			n = next
+		}
+		for _, p := range mt.nodes[n].out {
+			f(p, i+1)
+		}
+	}
+}
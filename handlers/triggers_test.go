@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gobridge/gopher/bot"
+	"github.com/nlopes/slack"
+)
+
+func TestMatcher(t *testing.T) {
+	patterns := []string{"he", "she", "his", "hers", "buffalo", "gobuffalo", "emacs", "spacemacs", "︵", "彡", "beer me", ""}
+	mt := newMatcher(patterns, false)
+
+	texts := []string{
+		"",
+		"ushers",
+		"I love gobuffalo",
+		"spacemacs > emacs",
+		"(╯°□°）╯︵ ┻━┻",
+		"beer m",
+		"this is his",
+		"BUFFALO",
+	}
+	for _, text := range texts {
+		t.Run(text, func(t *testing.T) {
+			expected := make([]bool, len(patterns))
+			for i, p := range patterns {
+				expected[i] = strings.Contains(text, p)
+			}
+			actual := make([]bool, len(patterns))
+			mt.match(text, func(p, end int) {
+				if !strings.HasSuffix(text[:end], patterns[p]) {
+					t.Errorf("pattern %q doesn't end at %d", patterns[p], end)
+				}
+				actual[p] = true
+			})
+			if !reflect.DeepEqual(expected, actual) {
+				t.Errorf("expected: %v\nactual: %v", expected, actual)
+			}
+		})
+	}
+}
+
+func TestTriggers(t *testing.T) {
+	var calls []string
+	record := func(name string) bot.Handler {
+		return bot.HandlerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) {
+			calls = append(calls, name)
+		})
+	}
+	h := Triggers(
+		Trigger{Substring: "vim", Handler: record("vim")},
+		record("always"),
+		Trigger{Substring: "emacs", Handler: record("emacs")},
+		Trigger{Substring: "spacemacs", Handler: record("spacemacs")},
+	)
+
+	tests := []struct {
+		text     string
+		expected []string
+	}{
+		{"spacemacs", []string{"always", "emacs", "spacemacs"}},
+		{"vim or emacs", []string{"vim", "always", "emacs"}},
+		{"nano", []string{"always"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			calls = nil
+			h.Handle(context.Background(), bot.Message{Event: &slack.MessageEvent{Msg: slack.Msg{Text: tt.text}}}, nil)
+			if !reflect.DeepEqual(tt.expected, calls) {
+				t.Errorf("expected: %v\nactual: %v", tt.expected, calls)
+			}
+		})
+	}
+}
+
+func TestTriggerModes(t *testing.T) {
+	tests := []struct {
+		name     string
+		trigger  Trigger
+		text     string
+		expected bool
+	}{
+		{"substring", Trigger{Substring: "dragon"}, "dragonfly", true},
+		{"case sensitive", Trigger{Substring: "bbq"}, "BBQ time", false},
+		{"whole word", Trigger{Substring: "dragon"}.WholeWord(), "dragonfly", false},
+		{"whole word at end", Trigger{Substring: "dragon"}.WholeWord(), "here be a dragon!", true},
+		{"whole word after partial match", Trigger{Substring: "dragon"}.WholeWord(), "dragonfly or dragon", true},
+		{"whole word inside unicode word", Trigger{Substring: "spacex"}.WholeWord(), "éspacex", false},
+		{"ignore case", Trigger{Substring: "bbq"}.IgnoreCase(), "BBQ time", true},
+		{"ignore case whole word", Trigger{Substring: "spacex"}.IgnoreCase().WholeWord(), "Spacexyz", false},
+		{"regexp", ReactWhenMatches(regexp.MustCompile(`(?i)erm(er|ah)gerd`)), "ERMAHGERD", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := tt.trigger.matches(tt.text); actual != tt.expected {
+				t.Errorf("expected: %t\nactual: %t", tt.expected, actual)
+			}
+
+			var matched bool
+			tt.trigger.Handler = bot.HandlerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) {
+				matched = true
+			})
+			Triggers(tt.trigger).Handle(context.Background(), bot.Message{Event: &slack.MessageEvent{Msg: slack.Msg{Text: tt.text}}}, nil)
+			if matched != tt.expected {
+				t.Errorf("expected indexed: %t\nactual: %t", tt.expected, matched)
+			}
+		})
+	}
+}
+
+func TestTriggerCooldown(t *testing.T) {
+	var calls []string
+	trigger := Trigger{
+		Substring: "bbq",
+		Handler: bot.HandlerFunc(func(ctx context.Context, m bot.Message, r bot.Responder) {
+			calls = append(calls, m.Event.Channel)
+		}),
+	}.Cooldown(time.Minute)
+
+	now := time.Now()
+	trigger.cooldown.now = func() time.Time { return now }
+	send := func(channel string) {
+		trigger.Handle(context.Background(), bot.Message{Event: &slack.MessageEvent{Msg: slack.Msg{Channel: channel, Text: "bbq"}}}, nil)
+	}
+
+	send("C1")
+	send("C1")
+	send("C2")
+	now = now.Add(time.Minute)
+	send("C1")
+
+	expected := []string{"C1", "C2", "C1"}
+	if !reflect.DeepEqual(expected, calls) {
+		t.Errorf("expected: %v\nactual: %v", expected, calls)
+	}
+}
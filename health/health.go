@@ -0,0 +1,207 @@
+// Package health tracks whether the subsystems of the bot, such as the Slack
+// connection and the pollers, work, from the last time they succeeded.
+package health
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Statuses of a check, and of a Registry.
+const (
+	// StatusOK is a check which succeeded last time.
+	StatusOK = "ok"
+	// StatusPending is a check which didn't report yet.
+	StatusPending = "pending"
+	// StatusFailing is a check which failed last time, but succeeded
+	// recently enough to be considered healthy.
+	StatusFailing = "failing"
+	// StatusUnhealthy is a check which didn't succeed for longer than its
+	// maximum age.
+	StatusUnhealthy = "unhealthy"
+	// StatusNotReady is a Registry which is live, but the last report of a
+	// critical check didn't succeed or is missing.
+	StatusNotReady = "not_ready"
+	// StatusDegraded is a Registry which is ready, but whose non-critical
+	// checks aren't all healthy.
+	StatusDegraded = "degraded"
+)
+
+// Registry holds the checks the subsystems report into.
+type Registry struct {
+	now func() time.Time
+
+	mu     sync.Mutex
+	checks []*Check
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{now: time.Now}
+}
+
+// Register adds a check named name. The check is unhealthy when it didn't
+// succeed in the last maxAge, or since it was registered. The bot is neither
+// ready nor live when a critical check isn't.
+func (r *Registry) Register(name string, maxAge time.Duration, critical bool) *Check {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := &Check{
+		name:       name,
+		maxAge:     maxAge,
+		critical:   critical,
+		now:        r.now,
+		registered: r.now(),
+	}
+	r.checks = append(r.checks, c)
+	return c
+}
+
+// Ready reports whether the last report of every critical check succeeded,
+// so the bot can serve requests.
+func (r *Registry) Ready() bool {
+	return r.Status().Ready()
+}
+
+// Live reports whether every critical check is healthy. When it isn't, the
+// bot is stuck and should be restarted.
+func (r *Registry) Live() bool {
+	return r.Status().Live()
+}
+
+// Status is the detailed status of a Registry.
+type Status struct {
+	Status string        `json:"status"` // ok, degraded, not_ready or unhealthy
+	Checks []CheckStatus `json:"checks"`
+}
+
+// Ready reports whether the last report of every critical check succeeded.
+func (s Status) Ready() bool {
+	for _, c := range s.Checks {
+		if c.Critical && c.Status != StatusOK {
+			return false
+		}
+	}
+	return true
+}
+
+// Live reports whether every critical check is healthy.
+func (s Status) Live() bool {
+	for _, c := range s.Checks {
+		if c.Critical && c.Status == StatusUnhealthy {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckStatus is the status of a check.
+type CheckStatus struct {
+	Name        string     `json:"name"`
+	Status      string     `json:"status"`
+	Critical    bool       `json:"critical"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastFailure *time.Time `json:"last_failure,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	Failures    int        `json:"consecutive_failures"`
+}
+
+// Status returns the status of every check, sorted by name. The status of
+// the Registry agrees with Live and Ready: it's unhealthy when not live, and
+// not_ready when live but not ready.
+func (r *Registry) Status() Status {
+	r.mu.Lock()
+	checks := append([]*Check(nil), r.checks...)
+	r.mu.Unlock()
+
+	var s Status
+	degraded := false
+	for _, c := range checks {
+		cs := c.status()
+		if cs.Status == StatusUnhealthy {
+			degraded = true
+		}
+		s.Checks = append(s.Checks, cs)
+	}
+	sort.Slice(s.Checks, func(i, j int) bool { return s.Checks[i].Name < s.Checks[j].Name })
+
+	switch {
+	case !s.Live():
+		s.Status = StatusUnhealthy
+	case !s.Ready():
+		s.Status = StatusNotReady
+	case degraded:
+		s.Status = StatusDegraded
+	default:
+		s.Status = StatusOK
+	}
+	return s
+}
+
+// Check is a subsystem reporting whether it works. A nil *Check ignores
+// reports, so subsystems can report whether or not they're checked.
+type Check struct {
+	name       string
+	maxAge     time.Duration
+	critical   bool
+	now        func() time.Time
+	registered time.Time
+
+	mu          sync.Mutex
+	lastSuccess time.Time
+	lastFailure time.Time
+	lastErr     error
+	failures    int
+}
+
+// Report records that the subsystem succeeded if err is nil, or failed.
+func (c *Check) Report(err error) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err == nil {
+		c.lastSuccess = c.now()
+		c.failures = 0
+		return
+	}
+	c.lastFailure = c.now()
+	c.lastErr = err
+	c.failures++
+}
+
+func (c *Check) status() CheckStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cs := CheckStatus{Name: c.name, Critical: c.critical, Failures: c.failures}
+	if !c.lastSuccess.IsZero() {
+		t := c.lastSuccess
+		cs.LastSuccess = &t
+	}
+	if !c.lastFailure.IsZero() {
+		t := c.lastFailure
+		cs.LastFailure = &t
+		cs.LastError = c.lastErr.Error()
+	}
+
+	since := c.lastSuccess
+	if since.IsZero() {
+		since = c.registered
+	}
+	switch {
+	case c.now().Sub(since) > c.maxAge:
+		cs.Status = StatusUnhealthy
+	case c.lastSuccess.IsZero() && c.lastFailure.IsZero():
+		cs.Status = StatusPending
+	case c.failures > 0:
+		cs.Status = StatusFailing
+	default:
+		cs.Status = StatusOK
+	}
+	return cs
+}
@@ -0,0 +1,56 @@
+package health
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	now := time.Now()
+	r := NewRegistry()
+	r.now = func() time.Time { return now }
+
+	slack := r.Register("slack", 5*time.Minute, true)
+	gerrit := r.Register("gerrit", time.Hour, false)
+
+	check := func(name, status string, ready, live bool) {
+		t.Helper()
+		s := r.Status()
+		if s.Status != status {
+			t.Errorf("%s: expected: %s\nactual: %s", name, status, s.Status)
+		}
+		if r.Ready() != ready {
+			t.Errorf("%s: expected ready: %t\nactual: %t", name, ready, r.Ready())
+		}
+		if r.Live() != live {
+			t.Errorf("%s: expected live: %t\nactual: %t", name, live, r.Live())
+		}
+	}
+
+	check("pending", StatusNotReady, false, true)
+
+	slack.Report(nil)
+	gerrit.Report(nil)
+	check("succeeded", StatusOK, true, true)
+
+	now = now.Add(time.Minute)
+	slack.Report(errors.New("invalid_auth"))
+	check("failing", StatusNotReady, false, true)
+
+	now = now.Add(5 * time.Minute)
+	check("stuck", StatusUnhealthy, false, false)
+
+	slack.Report(nil)
+	now = now.Add(time.Hour)
+	slack.Report(nil)
+	check("degraded", StatusDegraded, true, true)
+
+	s := r.Status()
+	if s.Checks[0].Name != "gerrit" || s.Checks[0].Status != StatusUnhealthy {
+		t.Errorf("expected gerrit to be unhealthy, got %+v", s.Checks[0])
+	}
+	if c := s.Checks[1]; c.LastError != "invalid_auth" || c.Failures != 0 || !c.LastSuccess.Equal(now) {
+		t.Errorf("expected slack to have recovered, got %+v", c)
+	}
+}
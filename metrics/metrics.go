@@ -0,0 +1,63 @@
+// Package metrics creates the Prometheus metrics of the bot. They are
+// registered with the default registry of the Prometheus client, which
+// promhttp.Handler serves on /metrics along with the Go runtime metrics.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultBuckets are histogram buckets, in seconds, suited to the latency of
+// handlers and API calls.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// NewCounter creates a counter named name without labels.
+func NewCounter(name, help string) prometheus.Counter {
+	return register(prometheus.NewCounter(prometheus.CounterOpts{
+		Name: name,
+		Help: help,
+	})).(prometheus.Counter)
+}
+
+// NewCounterVec creates a counter named name, whose values are broken down by
+// labels.
+func NewCounterVec(name, help string, labels ...string) *prometheus.CounterVec {
+	return register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: name,
+		Help: help,
+	}, labels)).(*prometheus.CounterVec)
+}
+
+// NewHistogramVec creates a histogram named name counting observations in
+// buckets, which are upper bounds in increasing order, broken down by labels.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *prometheus.HistogramVec {
+	return register(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    name,
+		Help:    help,
+		Buckets: buckets,
+	}, labels)).(*prometheus.HistogramVec)
+}
+
+// register registers c with the default registry. When the same metric was
+// registered before, the existing one is returned so both record into the
+// same series. Metrics which conflict with another one, such as a name used
+// with different labels, are programming errors and panic.
+func register(c prometheus.Collector) prometheus.Collector {
+	err := prometheus.Register(c)
+	if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+		return are.ExistingCollector
+	}
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Result is the value of a "result" label for an operation which returned
+// err: "ok" or "error".
+func Result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
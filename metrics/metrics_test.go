@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRegister(t *testing.T) {
+	events := NewCounterVec("gopher_test_events_total", "Events received.", "type")
+	again := NewCounterVec("gopher_test_events_total", "Events received.", "type")
+	if again != events {
+		t.Fatalf("expected registering the counter again to return it")
+	}
+
+	events.WithLabelValues("message").Inc()
+	again.WithLabelValues("message").Inc()
+	if v := testutil.ToFloat64(events.WithLabelValues("message")); v != 2 {
+		t.Errorf("expected: 2\nactual: %v", v)
+	}
+
+	defer func() {
+		if v := recover(); v == nil || !strings.Contains(v.(error).Error(), "gopher_test_events_total") {
+			t.Errorf("expected conflicting counter to panic, got %v", v)
+		}
+	}()
+	NewCounterVec("gopher_test_events_total", "Events received.", "channel")
+}